@@ -0,0 +1,138 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "sync"
+
+// broadcastJob is one chunk of clients a broadcastPool worker should
+// fan data out to.
+type broadcastJob struct {
+	clients []*Client
+	data    []byte
+}
+
+// broadcastPool fans a message out to a set of clients across a fixed
+// number of worker goroutines instead of one goroutine walking every
+// client itself. It underlies both Hub's lobby broadcast and each
+// Room's broadcast, so the two share one copy of the dispatch/strike/
+// evict machinery instead of maintaining divergent copies.
+type broadcastPool struct {
+	workers        int
+	maxDropStrikes int
+	policy         SlowClientPolicy
+
+	jobs chan broadcastJob
+
+	// evict carries clients a worker gave up on back to the owner
+	// (Hub or Room), which is the only goroutine allowed to mutate its
+	// own client set. Sends to it are non-blocking: if it's
+	// momentarily full, the client is simply struck again on its next
+	// failed send instead of having the worker block on it. A worker
+	// blocking here while the owner's run loop blocks handing a job to
+	// jobs (because every worker is itself stuck trying to evict)
+	// would otherwise deadlock the two goroutines against each other.
+	evict chan *Client
+
+	strikesMu sync.Mutex
+	strikes   map[*Client]int
+}
+
+// newBroadcastPool creates a pool of workers tuned by workers/
+// jobQueueSize/maxDropStrikes and starts them running.
+func newBroadcastPool(workers, jobQueueSize, maxDropStrikes int, policy SlowClientPolicy) *broadcastPool {
+	p := &broadcastPool{
+		workers:        workers,
+		maxDropStrikes: maxDropStrikes,
+		policy:         policy,
+		jobs:           make(chan broadcastJob, jobQueueSize),
+		evict:          make(chan *Client, workers),
+		strikes:        make(map[*Client]int),
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+
+	return p
+}
+
+// dispatch splits clients into up to p.workers chunks and hands each
+// chunk to the worker pool, instead of fanning data out to every client
+// from the caller's own goroutine.
+func (p *broadcastPool) dispatch(clients []*Client, data []byte) {
+	if len(clients) == 0 {
+		return
+	}
+
+	chunkSize := (len(clients) + p.workers - 1) / p.workers
+	if chunkSize == 0 {
+		chunkSize = len(clients)
+	}
+
+	for i := 0; i < len(clients); i += chunkSize {
+		end := i + chunkSize
+		if end > len(clients) {
+			end = len(clients)
+		}
+		p.jobs <- broadcastJob{clients: clients[i:end], data: data}
+	}
+}
+
+// run delivers jobs to their target clients. How it handles a full send
+// buffer depends on p.policy: under EvictAfterStrikes the client is
+// struck rather than evicted immediately, and only offered to evict for
+// removal once it has accumulated maxDropStrikes consecutive failed
+// sends; under DropOldest its oldest queued message is discarded to
+// make room so the client is never evicted for being slow.
+func (p *broadcastPool) run() {
+	for job := range p.jobs {
+		for _, client := range job.clients {
+			select {
+			case client.send <- job.data:
+				p.clearStrikes(client)
+			default:
+				switch p.policy {
+				case DropOldest:
+					select {
+					case <-client.send:
+					default:
+					}
+					select {
+					case client.send <- job.data:
+					default:
+					}
+				default:
+					if p.strike(client) >= p.maxDropStrikes {
+						select {
+						case p.evict <- client:
+						default:
+							// Owner's run loop is busy draining evict;
+							// this client will be struck and retried
+							// on its next failed send rather than
+							// blocking this worker on it.
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// strike records a failed send for client and returns its new
+// consecutive-failure count.
+func (p *broadcastPool) strike(client *Client) int {
+	p.strikesMu.Lock()
+	defer p.strikesMu.Unlock()
+	p.strikes[client]++
+	return p.strikes[client]
+}
+
+// clearStrikes resets a client's consecutive-drop count, e.g. after a
+// successful send or once it has been evicted.
+func (p *broadcastPool) clearStrikes(client *Client) {
+	p.strikesMu.Lock()
+	defer p.strikesMu.Unlock()
+	delete(p.strikes, client)
+}
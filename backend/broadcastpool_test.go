@@ -0,0 +1,67 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchClients creates n Clients with a drained send channel, standing
+// in for n live connections each reading as fast as a real client would.
+func benchClients(n int) []*Client {
+	clients := make([]*Client, n)
+	for i := range clients {
+		c := &Client{send: make(chan []byte, 8)}
+		clients[i] = c
+		go func() {
+			for range c.send {
+			}
+		}()
+	}
+	return clients
+}
+
+// BenchmarkBroadcastPooled measures broadcastPool.dispatch fanning one
+// message out across a fixed worker pool, at connection counts large
+// enough (1k/10k) that a single goroutine walking every client starts
+// to show up as head-of-line blocking in Hub.run's select loop.
+func BenchmarkBroadcastPooled(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("%dconns", n), func(b *testing.B) {
+			clients := benchClients(n)
+			pool := newBroadcastPool(lobbyBroadcastWorkers, lobbyBroadcastQueueSize, lobbyMaxDropStrikes, EvictAfterStrikes)
+			data := []byte("hello")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pool.dispatch(clients, data)
+			}
+		})
+	}
+}
+
+// BenchmarkBroadcastSingleGoroutine measures the pre-worker-pool
+// approach this package replaced: one goroutine walking every client
+// directly, for comparison against BenchmarkBroadcastPooled at the same
+// connection counts.
+func BenchmarkBroadcastSingleGoroutine(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("%dconns", n), func(b *testing.B) {
+			clients := benchClients(n)
+			data := []byte("hello")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, c := range clients {
+					select {
+					case c.send <- data:
+					default:
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Broker is a pluggable message-bus backend that lets Hub.broadcast and
+// topic/room messages fan out across multiple server instances sitting
+// behind a load balancer, instead of staying process-local. RabbitMQ is
+// the first implementation; NATS/Redis can satisfy the same interface
+// later without touching Hub.
+type Broker interface {
+	// Publish sends msg under routingKey to every other instance
+	// connected to the broker.
+	Publish(routingKey string, msg []byte) error
+
+	// Subscribe returns a channel of messages received for this
+	// instance, tagged with the routing key they were published under.
+	Subscribe() (<-chan BrokerMessage, error)
+
+	// Close releases the underlying connection.
+	Close() error
+}
+
+// BrokerMessage is a message received from the Broker, along with the
+// routing key it was published under.
+type BrokerMessage struct {
+	RoutingKey string
+	Payload    []byte
+}
+
+// envelope wraps every message put on the bus with the ID of the
+// instance that originated it, so that instance can ignore its own
+// messages when they come back around (loopback suppression).
+type envelope struct {
+	OriginID string          `json:"originID"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+const brokerExchange = "iogame.broadcast"
+
+// RabbitMQBroker implements Broker on top of a RabbitMQ topic exchange.
+// Each instance declares its own auto-delete, exclusive queue bound to
+// the exchange with the routing key patterns it cares about (e.g.
+// "broadcast", "topic.#"), so a server only receives messages for
+// rooms/topics it actually hosts subscribers for.
+type RabbitMQBroker struct {
+	url        string
+	instanceID string
+
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   amqp.Queue
+
+	routingKeys []string
+}
+
+// NewRabbitMQBroker connects to the RabbitMQ server at url, declares the
+// shared topic exchange, and binds an instance-unique auto-delete
+// queue to the given routing keys (e.g. "room.<roomID>", "topic.<name>").
+// It makes up to 5 connection attempts with exponential backoff between
+// them, returning the last error if none succeeds.
+func NewRabbitMQBroker(url, instanceID string, routingKeys []string) (*RabbitMQBroker, error) {
+	b := &RabbitMQBroker{url: url, instanceID: instanceID, routingKeys: routingKeys}
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// connect (re)establishes the connection, channel, exchange and queue.
+func (b *RabbitMQBroker) connect() error {
+	backoff := time.Second
+	var lastErr error
+	for attempt := 0; attempt < 5; attempt++ {
+		conn, err := amqp.Dial(b.url)
+		if err != nil {
+			lastErr = err
+			log.Printf("rabbitmq: dial attempt %d failed: %v", attempt+1, err)
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		ch, err := conn.Channel()
+		if err != nil {
+			conn.Close()
+			lastErr = err
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		if err := ch.ExchangeDeclare(brokerExchange, "topic", true, false, false, false, nil); err != nil {
+			ch.Close()
+			conn.Close()
+			lastErr = err
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		queue, err := ch.QueueDeclare("iogame."+b.instanceID, false, true, true, false, nil)
+		if err != nil {
+			ch.Close()
+			conn.Close()
+			lastErr = err
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		var bindErr error
+		for _, key := range b.routingKeys {
+			if bindErr = ch.QueueBind(queue.Name, key, brokerExchange, false, nil); bindErr != nil {
+				ch.Close()
+				conn.Close()
+				break
+			}
+		}
+		if bindErr != nil {
+			lastErr = bindErr
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		b.conn = conn
+		b.channel = ch
+		b.queue = queue
+		return nil
+	}
+	return lastErr
+}
+
+// Publish marshals msg into an envelope tagged with this instance's ID
+// and publishes it under routingKey.
+func (b *RabbitMQBroker) Publish(routingKey string, msg []byte) error {
+	body, err := json.Marshal(envelope{OriginID: b.instanceID, Payload: msg})
+	if err != nil {
+		return err
+	}
+	return b.channel.Publish(brokerExchange, routingKey, false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+	})
+}
+
+// Subscribe consumes from this instance's queue, drops messages that
+// originated from this same instance, and unwraps the rest onto the
+// returned channel. It reconnects with backoff if the connection drops.
+func (b *RabbitMQBroker) Subscribe() (<-chan BrokerMessage, error) {
+	out := make(chan BrokerMessage, 256)
+
+	go func() {
+		for {
+			deliveries, err := b.channel.Consume(b.queue.Name, "", true, true, false, false, nil)
+			if err != nil {
+				log.Printf("rabbitmq: consume failed, reconnecting: %v", err)
+				if err := b.connect(); err != nil {
+					log.Printf("rabbitmq: reconnect failed: %v", err)
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+
+			for d := range deliveries {
+				var env envelope
+				if err := json.Unmarshal(d.Body, &env); err != nil {
+					log.Println("rabbitmq: error unmarshalling envelope:", err)
+					continue
+				}
+				if env.OriginID == b.instanceID {
+					continue // loopback suppression
+				}
+				out <- BrokerMessage{RoutingKey: d.RoutingKey, Payload: env.Payload}
+			}
+
+			// Channel closed (connection dropped); reconnect and resume.
+			log.Println("rabbitmq: delivery channel closed, reconnecting")
+			if err := b.connect(); err != nil {
+				log.Printf("rabbitmq: reconnect failed: %v", err)
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Close tears down the channel and connection.
+func (b *RabbitMQBroker) Close() error {
+	if b.channel != nil {
+		b.channel.Close()
+	}
+	if b.conn != nil {
+		return b.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "sync"
+
+// memoryBus fans a published message out to every subscribed
+// memoryBroker except the one that published it, standing in for a
+// real message bus in tests that need multiple Hub instances to share
+// a Broker without a real RabbitMQ.
+type memoryBus struct {
+	mu   sync.Mutex
+	subs map[string]chan BrokerMessage
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{subs: make(map[string]chan BrokerMessage)}
+}
+
+func (b *memoryBus) subscribe(instanceID string) <-chan BrokerMessage {
+	ch := make(chan BrokerMessage, 16)
+	b.mu.Lock()
+	b.subs[instanceID] = ch
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *memoryBus) publish(originID, routingKey string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, ch := range b.subs {
+		if id == originID {
+			continue
+		}
+		ch <- BrokerMessage{RoutingKey: routingKey, Payload: payload}
+	}
+}
+
+// memoryBroker implements Broker on top of a shared memoryBus, for
+// tests exercising multi-instance fan-out without a real RabbitMQ.
+type memoryBroker struct {
+	bus        *memoryBus
+	instanceID string
+}
+
+func (b *memoryBroker) Publish(routingKey string, msg []byte) error {
+	b.bus.publish(b.instanceID, routingKey, msg)
+	return nil
+}
+
+func (b *memoryBroker) Subscribe() (<-chan BrokerMessage, error) {
+	return b.bus.subscribe(b.instanceID), nil
+}
+
+func (b *memoryBroker) Close() error { return nil }
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -26,6 +27,11 @@ var (
 	space   = []byte{' '}
 )
 
+// nextConnID hands out a process-wide unique ConnID to each Client, so
+// recorded commands can be tied back to the connection that sent them
+// independently of whatever Player that connection joins as.
+var nextConnID int64
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -43,17 +49,33 @@ type Client struct {
 
 	player *Player
 
-	game *Game
+	// room is the Room this client currently belongs to, if any. A
+	// client must join a room before it can send a "join" message to
+	// spawn a player.
+	room *Room
+
+	// DisplayName, ConnectedAt and RemoteAddr are presence metadata
+	// surfaced in roster snapshots and admin/debug endpoints.
+	DisplayName string
+	ConnectedAt time.Time
+	RemoteAddr  string
+
+	// ConnID identifies this connection in its room's recorded command
+	// log (see Game.RecordCommand), independent of player identity.
+	ConnID int
 }
 
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
-		// Remove player from game if exists
-		if c.player != nil {
-			c.game.removePlayer(c.player.ID, false)
-			log.Printf("Player %d disconnected", c.player.ID)
+		if c.room != nil {
+			if c.player != nil {
+				log.Printf("Player %d disconnected", c.player.ID)
+			}
+			c.hub.LeaveRoom(c)
 		}
+		// Always unregister from the lobby too so any topic
+		// subscriptions made before or after joining a room are pruned.
+		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
@@ -81,19 +103,78 @@ func (c *Client) readPump() {
 
 		// Check message type
 		if messageType, ok := data["type"].(string); ok {
+			if c.room != nil {
+				c.room.game.RecordCommand(c.ConnID, messageType, data)
+			}
+
 			switch messageType {
+			case "create_room":
+				roomID, _ := data["roomID"].(string)
+				c.handleCreateRoom(roomID)
+
+			case "join_room":
+				roomID, _ := data["roomID"].(string)
+				channel, _ := data["channel"].(string)
+				c.handleJoinRoom(roomID, channel)
+
+			case "leave_room":
+				c.handleLeaveRoom()
+
+			case "list_rooms":
+				c.handleListRooms()
+
+			case "subscribe":
+				if topic, ok := data["topic"].(string); ok {
+					c.hub.Subscribe(c, topic)
+				}
+
+			case "unsubscribe":
+				if topic, ok := data["topic"].(string); ok {
+					c.hub.Unsubscribe(c, topic)
+				}
+
 			case "join":
-				// Create a new player only if the client doesn't already have one
-				if c.player == nil {
-					c.player = c.game.addNewPlayer()
+				// Create a new player only if the client has joined a room
+				// and doesn't already have one
+				if c.room != nil && c.player == nil {
+					if name, ok := data["name"].(string); ok {
+						c.DisplayName = name
+					}
+					c.player = c.room.game.addNewPlayer(c)
 
-					// Send acknowledgment back to client
+					// Send acknowledgment back to client, including a
+					// signed session token the client can use to resume
+					// this same Player if its connection drops.
 					response := map[string]any{
 						"type":     "joined",
 						"playerID": c.player.ID,
+						"token":    signSessionToken(c.player.ID, c.room.ID),
 					}
 					responseJSON, _ := json.Marshal(response)
 					c.send <- responseJSON
+
+					c.room.sendRoster(c)
+					c.room.broadcastPresence("join", c.player.ID)
+				}
+
+			case "resume":
+				if token, ok := data["token"].(string); ok {
+					c.handleResume(token)
+				}
+
+			case "create_party":
+				if partyID, ok := data["partyID"].(string); ok && c.player != nil {
+					c.handleCreateParty(partyID)
+				}
+
+			case "join_party":
+				if partyID, ok := data["partyID"].(string); ok && c.player != nil {
+					c.handleJoinParty(partyID)
+				}
+
+			case "leave_party":
+				if c.player != nil {
+					c.handleLeaveParty()
 				}
 
 			case "direction":
@@ -103,6 +184,17 @@ func (c *Client) readPump() {
 					c.player.Direction = direction
 				}
 
+			case "useWeapon":
+				if c.player != nil {
+					weaponIdx, _ := data["weaponIdx"].(float64)
+					targetX, _ := data["targetX"].(float64)
+					targetY, _ := data["targetY"].(float64)
+					if err := c.room.game.UseWeapon(c.player, int(weaponIdx), targetX, targetY); err != nil {
+						response, _ := json.Marshal(map[string]any{"type": "error", "error": err.Error()})
+						c.send <- response
+					}
+				}
+
 			default:
 				log.Println("unknown message type:", messageType)
 			}
@@ -149,7 +241,138 @@ func (c *Client) writePump() {
 	}
 }
 
-func serveWs(hub *Hub, game *Game, w http.ResponseWriter, r *http.Request) {
+// handleCreateRoom creates a new room and acknowledges the result.
+func (c *Client) handleCreateRoom(roomID string) {
+	_, err := c.hub.CreateRoom(roomID)
+	response := map[string]any{"type": "room_created", "roomID": roomID}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	responseJSON, _ := json.Marshal(response)
+	c.send <- responseJSON
+}
+
+// handleJoinRoom moves the client from the lobby (or its previous room)
+// into the room identified by roomID, on the given channel.
+func (c *Client) handleJoinRoom(roomID, channel string) {
+	room, ok := c.hub.GetRoom(roomID)
+	if !ok {
+		response, _ := json.Marshal(map[string]any{
+			"type":  "error",
+			"error": "room not found: " + roomID,
+		})
+		c.send <- response
+		return
+	}
+
+	if c.room != nil {
+		c.hub.LeaveRoom(c)
+	} else {
+		c.hub.LeaveLobby(c)
+	}
+
+	room.join(c, channel)
+
+	response, _ := json.Marshal(map[string]any{"type": "room_joined", "roomID": roomID})
+	c.send <- response
+}
+
+// handleLeaveRoom removes the client from its current room and returns
+// it to the lobby.
+func (c *Client) handleLeaveRoom() {
+	if c.room == nil {
+		return
+	}
+	c.hub.LeaveRoom(c)
+	c.player = nil
+	c.hub.register <- c
+}
+
+// handleListRooms replies with the IDs of every room currently hosted
+// by the hub.
+func (c *Client) handleListRooms() {
+	response, _ := json.Marshal(map[string]any{
+		"type":  "rooms",
+		"rooms": c.hub.ListRooms(),
+	})
+	c.send <- response
+}
+
+// handleCreateParty creates a new party led by the client's player.
+func (c *Client) handleCreateParty(partyID string) {
+	_, err := c.room.game.CreateParty(partyID, c.player.ID)
+	response := map[string]any{"type": "party_created", "partyID": partyID}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	responseJSON, _ := json.Marshal(response)
+	c.send <- responseJSON
+}
+
+// handleJoinParty adds the client's player to an existing party.
+func (c *Client) handleJoinParty(partyID string) {
+	err := c.room.game.JoinParty(partyID, c.player.ID)
+	response := map[string]any{"type": "party_joined", "partyID": partyID}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	responseJSON, _ := json.Marshal(response)
+	c.send <- responseJSON
+}
+
+// handleLeaveParty removes the client's player from its current party.
+func (c *Client) handleLeaveParty() {
+	c.room.game.LeaveParty(c.player.ID)
+	response, _ := json.Marshal(map[string]any{"type": "party_left"})
+	c.send <- response
+}
+
+// handleResume verifies a session token and, if it's still valid and
+// the claimed Player is still within its grace period, re-binds that
+// Player to this client instead of making it start over.
+func (c *Client) handleResume(token string) {
+	claims, err := verifySessionToken(token)
+	if err != nil {
+		response, _ := json.Marshal(map[string]any{"type": "error", "error": "invalid session token"})
+		c.send <- response
+		return
+	}
+
+	room, ok := c.hub.GetRoom(claims.RoomID)
+	if !ok {
+		response, _ := json.Marshal(map[string]any{"type": "error", "error": "room not found: " + claims.RoomID})
+		c.send <- response
+		return
+	}
+
+	player, ok := room.game.resumePlayer(claims.PlayerID, c)
+	if !ok {
+		response, _ := json.Marshal(map[string]any{"type": "error", "error": "session expired"})
+		c.send <- response
+		return
+	}
+
+	if c.room != nil {
+		c.hub.LeaveRoom(c)
+	} else {
+		c.hub.LeaveLobby(c)
+	}
+
+	c.player = player
+	room.join(c, ChannelPlayer)
+
+	response, _ := json.Marshal(map[string]any{
+		"type":     "resumed",
+		"playerID": player.ID,
+		"token":    signSessionToken(player.ID, room.ID),
+	})
+	c.send <- response
+
+	room.sendRoster(c)
+	room.broadcastPresence("join", player.ID)
+}
+
+func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
@@ -157,11 +380,13 @@ func serveWs(hub *Hub, game *Game, w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:    hub,
-		game:   game,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		player: nil, // Player will be created when client sends join message
+		hub:         hub,
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		player:      nil, // Player will be created when client sends join message
+		ConnectedAt: time.Now(),
+		RemoteAddr:  r.RemoteAddr,
+		ConnID:      int(atomic.AddInt64(&nextConnID, 1)),
 	}
 
 	client.hub.register <- client
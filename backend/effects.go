@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EffectTarget is anything an Effect can be applied to. Both Player and
+// Monster satisfy it through their embedded HealthComponent.
+type EffectTarget interface {
+	Hittable
+	Heal(amount int) int
+}
+
+// Effect is a timed modifier applied to a Player or Monster: a buff,
+// damage-over-time, or heal-over-time. EffectManager drives it from the
+// game tick, calling Tick whenever NextTime has arrived and Unapply once
+// Deadline has passed. Deadline/NextTime are expressed in Game.TickNumber
+// ticks rather than wall-clock time, so effect timing reproduces
+// identically under Replay.
+type Effect interface {
+	// Apply runs once, when the effect is first added to a target.
+	Apply(target EffectTarget)
+	// Tick runs every time NextTime arrives, and is responsible for
+	// advancing NextTime to the next scheduled tick.
+	Tick(target EffectTarget)
+	// Unapply runs once, when the effect expires.
+	Unapply(target EffectTarget)
+	// Deadline is the tick the effect expires and is removed.
+	Deadline() int
+	// NextTime is the tick Tick should next run.
+	NextTime() int
+	// Name identifies the effect for the gameState broadcast, so the
+	// client can render the right icon/timer.
+	Name() string
+}
+
+// EffectManager tracks the effects currently active on a single target.
+type EffectManager struct {
+	effects []Effect
+}
+
+// NewEffectManager creates an empty EffectManager.
+func NewEffectManager() *EffectManager {
+	return &EffectManager{effects: []Effect{}}
+}
+
+// Add applies a new effect to target and starts tracking it.
+func (em *EffectManager) Add(effect Effect, target EffectTarget) {
+	effect.Apply(target)
+	em.effects = append(em.effects, effect)
+}
+
+// Update ticks every effect due at currentTick and drops any whose
+// deadline has passed. currentTick should be Game.TickNumber.
+func (em *EffectManager) Update(target EffectTarget, currentTick int) {
+	remaining := make([]Effect, 0, len(em.effects))
+	for _, effect := range em.effects {
+		if currentTick > effect.Deadline() {
+			effect.Unapply(target)
+			continue
+		}
+		if currentTick >= effect.NextTime() {
+			effect.Tick(target)
+		}
+		remaining = append(remaining, effect)
+	}
+	em.effects = remaining
+}
+
+// effectSnapshot is the JSON-facing view of an active effect.
+type effectSnapshot struct {
+	Name     string `json:"name"`
+	Deadline int    `json:"deadline"`
+}
+
+// MarshalJSON serializes the manager's active effects as a plain array,
+// so Player/Monster can embed an EffectManager directly and have it
+// show up as an "effects" field in the gameState broadcast.
+func (em *EffectManager) MarshalJSON() ([]byte, error) {
+	snapshots := make([]effectSnapshot, len(em.effects))
+	for i, effect := range em.effects {
+		snapshots[i] = effectSnapshot{Name: effect.Name(), Deadline: effect.Deadline()}
+	}
+	return json.Marshal(snapshots)
+}
+
+// ticksFromDuration converts a wall-clock duration into the equivalent
+// number of ticks at simulationFPS, so an effect's lifetime can still
+// be specified in a readable duration while being scheduled off
+// TickNumber.
+func ticksFromDuration(d time.Duration) int {
+	return int(d * simulationFPS / time.Second)
+}
+
+// damageOverTimeEffect deals DamagePerTick damage every tickInterval
+// ticks until deadline, e.g. poison or burn.
+type damageOverTimeEffect struct {
+	name          string
+	damagePerTick int
+	tickInterval  int
+	deadline      int
+	nextTime      int
+}
+
+// newDamageOverTimeEffect creates a damage-over-time effect lasting
+// durationTicks ticks and ticking every tickIntervalTicks ticks,
+// starting at startTick (the tick it's applied on).
+func newDamageOverTimeEffect(name string, damagePerTick, tickIntervalTicks, durationTicks, startTick int) *damageOverTimeEffect {
+	return &damageOverTimeEffect{
+		name:          name,
+		damagePerTick: damagePerTick,
+		tickInterval:  tickIntervalTicks,
+		deadline:      startTick + durationTicks,
+		nextTime:      startTick + tickIntervalTicks,
+	}
+}
+
+func (e *damageOverTimeEffect) Apply(target EffectTarget) {}
+
+func (e *damageOverTimeEffect) Tick(target EffectTarget) {
+	target.TakeDamage(e.damagePerTick)
+	e.nextTime += e.tickInterval
+}
+
+func (e *damageOverTimeEffect) Unapply(target EffectTarget) {}
+
+func (e *damageOverTimeEffect) Deadline() int { return e.deadline }
+
+func (e *damageOverTimeEffect) NextTime() int { return e.nextTime }
+
+func (e *damageOverTimeEffect) Name() string { return e.name }
+
+// NewPoisonEffect deals damagePerTick damage once a second for 5
+// seconds, the DoT monsters inflict on a bite. startTick is the tick
+// it's applied on (normally Game.TickNumber).
+func NewPoisonEffect(damagePerTick, startTick int) Effect {
+	return newDamageOverTimeEffect("poison", damagePerTick, ticksFromDuration(time.Second), ticksFromDuration(5*time.Second), startTick)
+}
+
+// NewBurnEffect deals damagePerTick damage twice a second for 3
+// seconds, a faster but shorter-lived DoT than poison. startTick is
+// the tick it's applied on (normally Game.TickNumber).
+func NewBurnEffect(damagePerTick, startTick int) Effect {
+	return newDamageOverTimeEffect("burn", damagePerTick, ticksFromDuration(500*time.Millisecond), ticksFromDuration(3*time.Second), startTick)
+}
+
+// healOverTimeEffect heals HealPerTick every tickInterval ticks until
+// deadline, e.g. a healing potion's regen.
+type healOverTimeEffect struct {
+	name         string
+	healPerTick  int
+	tickInterval int
+	deadline     int
+	nextTime     int
+}
+
+// newHealOverTimeEffect creates a heal-over-time effect lasting
+// durationTicks ticks and ticking every tickIntervalTicks ticks,
+// starting at startTick (the tick it's applied on).
+func newHealOverTimeEffect(name string, healPerTick, tickIntervalTicks, durationTicks, startTick int) *healOverTimeEffect {
+	return &healOverTimeEffect{
+		name:         name,
+		healPerTick:  healPerTick,
+		tickInterval: tickIntervalTicks,
+		deadline:     startTick + durationTicks,
+		nextTime:     startTick + tickIntervalTicks,
+	}
+}
+
+func (e *healOverTimeEffect) Apply(target EffectTarget) {}
+
+func (e *healOverTimeEffect) Tick(target EffectTarget) {
+	target.Heal(e.healPerTick)
+	e.nextTime += e.tickInterval
+}
+
+func (e *healOverTimeEffect) Unapply(target EffectTarget) {}
+
+func (e *healOverTimeEffect) Deadline() int { return e.deadline }
+
+func (e *healOverTimeEffect) NextTime() int { return e.nextTime }
+
+func (e *healOverTimeEffect) Name() string { return e.name }
+
+// NewRegenEffect spreads a HealingPotion's amount over 5 one-second
+// ticks instead of healing it all at once. startTick is the tick it's
+// applied on (normally Game.TickNumber).
+func NewRegenEffect(totalAmount, startTick int) Effect {
+	const ticks = 5
+	perTick := totalAmount / ticks
+	if perTick < 1 {
+		perTick = 1
+	}
+	return newHealOverTimeEffect("regen", perTick, ticksFromDuration(time.Second), ticksFromDuration(ticks*time.Second), startTick)
+}
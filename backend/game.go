@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"math"
 	"math/rand"
@@ -9,12 +10,35 @@ import (
 	"time"
 )
 
+// simulationFPS is how many ticks Game.Step advances per simulated
+// second, both in the live Room loop and under Replay. Any in-game
+// timer (hit cooldowns, effect schedules, AI timers) is expressed in
+// ticks derived from this constant rather than wall-clock time, so it
+// stays reproducible when Replay re-runs the same seed and commands.
+const simulationFPS = 60
+
 const (
-	// Cooldown duration between hits from the same weapon (in milliseconds)
-	weaponHitCooldown = 1000 * time.Millisecond
-	playerMasterRatio = 2
-	baseMonsterAmount = 10
-	maxMonsterAmount  = 60
+	// weaponHitCooldownTicks is how many ticks must pass before the
+	// same weapon/monster ID can hit the same target again (1 second).
+	weaponHitCooldownTicks = simulationFPS
+	playerMasterRatio      = 2
+	baseMonsterAmount      = 10
+	maxMonsterAmount       = 60
+
+	// playerGracePeriod is how long a disconnected player's Player
+	// lingers in the game, waiting for a "resume" session token, before
+	// it is removed for good. It's tied to sessionTokenTTL so a token
+	// that's still valid is never rejected as "session expired" because
+	// the Player it would resume was already reaped.
+	playerGracePeriod = sessionTokenTTL
+
+	// partyExpShareRadius is how close a party member must be to a
+	// kill site to receive a share of that kill's experience.
+	partyExpShareRadius = 400.0
+
+	// partySoloBonusPercent is the extra share, on top of an even
+	// split, the player who landed the killing blow receives.
+	partySoloBonusPercent = 20
 
 	// Game boundary constants
 	gameMinX = 0
@@ -55,10 +79,12 @@ type ItemCollector interface {
 	CollectItem(item *Item)
 }
 
-// Item is an interface for collectible entities
+// Item is an interface for collectible entities. currentTick is
+// threaded through so an OnCollect implementation that grants a timed
+// Effect can schedule it off TickNumber instead of wall-clock time.
 type Item interface {
 	Collidable
-	OnCollect(collector ItemCollector)
+	OnCollect(collector ItemCollector, currentTick int)
 }
 
 // GetEntity returns the base Entity of an object
@@ -85,7 +111,7 @@ func (e *Entity) CheckCollision(other *Entity) bool {
 type HealthComponent struct {
 	Health      int
 	MaxHealth   int
-	lastHitById map[int]time.Time
+	lastHitById map[int]int // tick of last hit, by weapon/monster ID
 }
 
 // TakeDamage reduces health by the given amount
@@ -113,11 +139,12 @@ func (h *HealthComponent) IsDead() bool {
 	return h.Health <= 0
 }
 
-// Check hit cooldown
-func (h *HealthComponent) CheckHitCooldown(weaponID int) bool {
-	lastHitTime, hit := h.lastHitById[weaponID]
-	if !hit || time.Since(lastHitTime) >= weaponHitCooldown {
-		h.lastHitById[weaponID] = time.Now()
+// CheckHitCooldown reports whether weaponID may hit this target again
+// at currentTick, and if so records currentTick as its last hit.
+func (h *HealthComponent) CheckHitCooldown(weaponID int, currentTick int) bool {
+	lastHitTick, hit := h.lastHitById[weaponID]
+	if !hit || currentTick-lastHitTick >= weaponHitCooldownTicks {
+		h.lastHitById[weaponID] = currentTick
 		return true
 	}
 	return false
@@ -151,6 +178,17 @@ type Player struct {
 	WeaponRotationSpeed float64
 	Weapons             []*Weapon
 	Client              *Client
+
+	// Effects tracks the buffs/DoTs/HoTs currently active on the
+	// player (e.g. a monster's poison bite, a potion's regen).
+	Effects *EffectManager `json:"effects"`
+
+	// DisconnectedAt is set when the player's Client drops, and cleared
+	// again on resume. A zero value means the player is connected. This
+	// lets the player linger in the game for playerGracePeriod instead
+	// of being removed the instant the socket closes, so a reconnecting
+	// client can resume the same Player.
+	DisconnectedAt time.Time
 }
 
 // Move updates the player's position based on direction and speed
@@ -187,11 +225,86 @@ func (p *Player) CollectItem(item *Item) {
 	// Implemented by specific item types
 }
 
+// GainExperience adds amount to the player's experience and applies a
+// level-up if it crosses the threshold, reporting whether it did.
+// Shared by Experience.OnCollect and party XP sharing so both award
+// experience the same way.
+func (p *Player) GainExperience(amount int) bool {
+	p.Experience += amount
+	if p.Experience >= p.Level*10 {
+		p.Level++
+		p.Damage++
+		p.MaxHealth += 10
+		p.Health = p.MaxHealth
+		p.Experience = 0
+		return true
+	}
+	return false
+}
+
+// WeaponKind distinguishes how a Weapon delivers its damage.
+type WeaponKind int
+
+const (
+	// WeaponKindMelee orbits the player and damages on contact, as the
+	// original two starting weapons always have.
+	WeaponKindMelee WeaponKind = iota
+	// WeaponKindProjectile is fired at a target point and travels as
+	// its own entity until it hits something or its TTL expires.
+	WeaponKindProjectile
+	// WeaponKindAoE resolves immediately against every target within
+	// Radius of the target point, instead of moving or orbiting.
+	WeaponKindAoE
+)
+
+// InflictsDamage decouples a weapon's damage from its wielder's own
+// AttackComponent, so new weapon kinds (fireballs, chain lightning,
+// ...) can carry their own damage without changing Player.
+type InflictsDamage struct {
+	Damage int
+}
+
 // Weapon represents a player's weapon
 type Weapon struct {
 	Entity
 	OwnerID       int
 	RotationAngle float64
+
+	// Kind selects how the weapon is used. Melee weapons keep orbiting
+	// the player as before; Projectile and AoE weapons are triggered
+	// by a "useWeapon" message aimed at a target point.
+	Kind WeaponKind
+	// Range is how far from the owner a Projectile/AoE weapon can be
+	// targeted.
+	Range float64
+	// Radius is the blast radius for an AoE weapon.
+	Radius float64
+
+	InflictsDamage
+}
+
+// Projectile is a fired Projectile-kind weapon instance travelling
+// under its own velocity until it hits something or its TTL elapses.
+type Projectile struct {
+	Entity
+	OwnerID    int
+	VelocityX  float64
+	VelocityY  float64
+	TTL        float64 // seconds remaining
+	hitTargets map[int]bool
+	InflictsDamage
+}
+
+// Move advances the projectile and counts down its remaining lifetime.
+func (p *Projectile) Move(deltaTime float64) {
+	p.X += p.VelocityX * deltaTime
+	p.Y += p.VelocityY * deltaTime
+	p.TTL -= deltaTime
+}
+
+// Expired reports whether the projectile's TTL has run out.
+func (p *Projectile) Expired() bool {
+	return p.TTL <= 0
 }
 
 // Monster represents an enemy in the game
@@ -201,11 +314,32 @@ type Monster struct {
 	MovementComponent
 	AttackComponent
 	DropRate float64
+
+	// Type selects which archetype this monster was spawned as; it's
+	// mostly informational since Behavior already captures how the
+	// monster acts, but the client uses it to pick a sprite.
+	Type MonsterType
+
+	// Behavior decides how the monster moves (and, for some
+	// archetypes, attacks) each tick. It's picked at spawn by
+	// newBehaviorForType and isn't meaningful to serialize.
+	Behavior AIBehavior `json:"-"`
+
+	// Effects tracks the buffs/DoTs/HoTs currently active on the
+	// monster.
+	Effects *EffectManager `json:"effects"`
+
+	// LastHitByPlayerID is the ID of the last player to damage this
+	// monster, used to attribute its death for party XP sharing.
+	LastHitByPlayerID int `json:"-"`
 }
 
-// Move updates the monster's position
-func (m *Monster) Move(deltaTime float64) {
-	// Simple random movement
+// Move advances the monster deltaTime forward along its current
+// Direction at its current Speed, bouncing off the world bounds. Move
+// itself draws nothing from rng; AIBehavior implementations steer
+// Direction (and Speed) before calling Move, taking any randomness
+// they need from the match's seeded RNG.
+func (m *Monster) Move(deltaTime float64, rng *rand.Rand) {
 	newX := m.X + math.Cos(m.Direction)*m.Speed*deltaTime
 	newY := m.Y + math.Sin(m.Direction)*m.Speed*deltaTime
 
@@ -234,35 +368,234 @@ func (m *Monster) Move(deltaTime float64) {
 	// Update monster position
 	m.X = newX
 	m.Y = newY
+}
+
+// MonsterType is the archetype a Monster was spawned as, which picks
+// its AIBehavior at spawn time (see newBehaviorForType).
+type MonsterType int
+
+const (
+	// MonsterTypeGrunt wanders aimlessly and never seeks out players.
+	MonsterTypeGrunt MonsterType = iota
+	// MonsterTypeHunter chases the nearest player within its aggro
+	// radius.
+	MonsterTypeHunter
+	// MonsterTypeCoward wanders normally until hurt, then flees the
+	// nearest player.
+	MonsterTypeCoward
+	// MonsterTypeLeaper chases like a hunter but periodically dashes
+	// at its target in a burst of speed.
+	MonsterTypeLeaper
+)
+
+const (
+	monsterChaseAggroRadius  = 250.0
+	monsterFleeHealthFrac    = 0.25
+	monsterLeapAggroRadius   = 200.0
+	monsterLeapIntervalTicks = 3 * simulationFPS // 3 seconds
+	monsterLeapSpeedMul      = 4.0
+)
+
+// newBehaviorForType constructs the AIBehavior matching monsterType.
+func newBehaviorForType(monsterType MonsterType) AIBehavior {
+	switch monsterType {
+	case MonsterTypeHunter:
+		return ChaseBehavior{AggroRadius: monsterChaseAggroRadius}
+	case MonsterTypeCoward:
+		return FleeBehavior{FleeHealthFrac: monsterFleeHealthFrac}
+	case MonsterTypeLeaper:
+		return NewLeapAttackBehavior(monsterLeapAggroRadius)
+	default:
+		return WanderBehavior{}
+	}
+}
+
+// monsterTypeWeights returns each MonsterType's spawn weight for a
+// game whose players average avgLevel, skewing toward the more
+// aggressive archetypes as avgLevel climbs so higher-level games face
+// tougher monster mixes.
+func monsterTypeWeights(avgLevel float64) map[MonsterType]float64 {
+	return map[MonsterType]float64{
+		MonsterTypeGrunt:  math.Max(5-avgLevel*0.5, 1),
+		MonsterTypeHunter: 1 + avgLevel*0.8,
+		MonsterTypeCoward: 2,
+		MonsterTypeLeaper: avgLevel * 0.5,
+	}
+}
+
+// pickMonsterType randomly selects a MonsterType for a newly spawned
+// monster, weighted by the current players' average level.
+func (g *Game) pickMonsterType() MonsterType {
+	total := 0
+	avgLevel := 1.0
+	for _, p := range g.Players {
+		total += p.Level
+	}
+	if len(g.Players) > 0 {
+		avgLevel = float64(total) / float64(len(g.Players))
+	}
+
+	weights := monsterTypeWeights(avgLevel)
+	types := []MonsterType{MonsterTypeGrunt, MonsterTypeHunter, MonsterTypeCoward, MonsterTypeLeaper}
+
+	totalWeight := 0.0
+	for _, t := range types {
+		totalWeight += weights[t]
+	}
+
+	roll := g.rng.Float64() * totalWeight
+	for _, t := range types {
+		roll -= weights[t]
+		if roll <= 0 {
+			return t
+		}
+	}
+	return MonsterTypeGrunt
+}
+
+// AIBehavior governs how a Monster moves (and, for the more
+// aggressive archetypes, attacks) each tick. It's assigned once at
+// spawn based on the monster's MonsterType.
+type AIBehavior interface {
+	Update(m *Monster, g *Game, dt float64)
+}
+
+// WanderBehavior is the original aimless random walk: the monster
+// strolls in its current Direction, occasionally picking a new random
+// one.
+type WanderBehavior struct{}
+
+func (WanderBehavior) Update(m *Monster, g *Game, dt float64) {
+	if g.rng.Float64() < 0.01 {
+		m.Direction = g.rng.Float64() * 2 * math.Pi
+	}
+	m.Move(dt, g.rng)
+}
+
+// nearestPlayer returns the closest connected, living player within
+// radius of m, or nil if none qualify.
+func nearestPlayer(m *Monster, g *Game, radius float64) *Player {
+	var closest *Player
+	closestDistSq := radius * radius
+	for _, p := range g.Players {
+		if !p.DisconnectedAt.IsZero() || p.IsDead() {
+			continue
+		}
+		dx := p.X - m.X
+		dy := p.Y - m.Y
+		distSq := dx*dx + dy*dy
+		if distSq <= closestDistSq {
+			closest = p
+			closestDistSq = distSq
+		}
+	}
+	return closest
+}
+
+// ChaseBehavior steers the monster toward the nearest valid target
+// within AggroRadius, falling back to wandering once none is in
+// range.
+type ChaseBehavior struct {
+	AggroRadius float64
+}
+
+func (c ChaseBehavior) Update(m *Monster, g *Game, dt float64) {
+	target := nearestPlayer(m, g, c.AggroRadius)
+	if target == nil {
+		WanderBehavior{}.Update(m, g, dt)
+		return
+	}
+	m.Direction = math.Atan2(target.Y-m.Y, target.X-m.X)
+	m.Move(dt, g.rng)
+}
+
+// FleeBehavior wanders normally above FleeHealthFrac of max health,
+// and runs from the nearest player once it drops below that.
+type FleeBehavior struct {
+	FleeHealthFrac float64
+}
+
+func (f FleeBehavior) Update(m *Monster, g *Game, dt float64) {
+	if float64(m.Health) > f.FleeHealthFrac*float64(m.MaxHealth) {
+		WanderBehavior{}.Update(m, g, dt)
+		return
+	}
+	target := nearestPlayer(m, g, math.Inf(1))
+	if target == nil {
+		WanderBehavior{}.Update(m, g, dt)
+		return
+	}
+	m.Direction = math.Atan2(m.Y-target.Y, m.X-target.X)
+	m.Move(dt, g.rng)
+}
+
+// LeapAttackBehavior chases like ChaseBehavior, but every
+// LeapIntervalTicks it dashes at its target in a burst of speed
+// instead of moving at its normal pace. Contact damage on landing is
+// handled the same way as any other monster bite, by
+// CollisionSystem.checkMonsterPlayerCollisions. The cooldown is
+// tracked in ticks rather than wall-clock time so it reproduces
+// identically under Replay.
+type LeapAttackBehavior struct {
+	AggroRadius       float64
+	LeapIntervalTicks int
+	LeapSpeedMul      float64
+
+	nextLeapTick int
+}
 
-	// Occasionally change direction
-	if rand.Float64() < 0.01 {
-		m.Direction = rand.Float64() * 2 * math.Pi
+// NewLeapAttackBehavior creates a LeapAttackBehavior that aggroes
+// within aggroRadius and leaps on the default cooldown/speed burst.
+func NewLeapAttackBehavior(aggroRadius float64) *LeapAttackBehavior {
+	return &LeapAttackBehavior{
+		AggroRadius:       aggroRadius,
+		LeapIntervalTicks: monsterLeapIntervalTicks,
+		LeapSpeedMul:      monsterLeapSpeedMul,
 	}
 }
 
+func (l *LeapAttackBehavior) Update(m *Monster, g *Game, dt float64) {
+	target := nearestPlayer(m, g, l.AggroRadius)
+	if target == nil {
+		WanderBehavior{}.Update(m, g, dt)
+		return
+	}
+	m.Direction = math.Atan2(target.Y-m.Y, target.X-m.X)
+
+	if g.TickNumber < l.nextLeapTick {
+		m.Move(dt, g.rng)
+		return
+	}
+
+	originalSpeed := m.Speed
+	m.Speed *= l.LeapSpeedMul
+	m.Move(dt, g.rng)
+	m.Speed = originalSpeed
+
+	l.nextLeapTick = g.TickNumber + l.LeapIntervalTicks
+}
+
 // HealingPotion represents a health recovery item
 type HealingPotion struct {
 	Entity
 	Amount int
 }
 
-// OnCollect handles what happens when the potion is collected
-func (h *HealingPotion) OnCollect(collector ItemCollector) {
+// OnCollect handles what happens when the potion is collected. Instead
+// of healing instantly, it grants a regen-over-time effect.
+func (h *HealingPotion) OnCollect(collector ItemCollector, currentTick int) {
 	if player, ok := collector.(*Player); ok {
 		if player.Client == nil {
 			return
 		}
-		healedAmount := player.Heal(h.Amount)
+		player.Effects.Add(NewRegenEffect(h.Amount, currentTick), player)
 
 		// Notify player about potion collection if possible
 		potionNotification, err := json.Marshal(map[string]interface{}{
-			"type":         "potionCollected",
-			"playerID":     player.ID,
-			"potionID":     h.ID,
-			"amount":       h.Amount,
-			"healedAmount": healedAmount,
-			"newHealth":    player.Health,
+			"type":     "potionCollected",
+			"playerID": player.ID,
+			"potionID": h.ID,
+			"amount":   h.Amount,
 		})
 		if err == nil {
 			player.Client.send <- potionNotification
@@ -277,18 +610,12 @@ type Experience struct {
 }
 
 // OnCollect handles what happens when experience is collected
-func (e *Experience) OnCollect(collector ItemCollector) {
+func (e *Experience) OnCollect(collector ItemCollector, currentTick int) {
 	if player, ok := collector.(*Player); ok {
 		if player.Client == nil {
 			return
 		}
-		player.Experience += e.Amount
-		if player.Experience >= player.Level*10 {
-			player.Level++
-			player.Damage++
-			player.MaxHealth += 10
-			player.Health = player.MaxHealth
-			player.Experience = 0
+		if player.GainExperience(e.Amount) {
 			levelUpNotification, err := json.Marshal(map[string]interface{}{
 				"type":     "levelUp",
 				"playerID": player.ID,
@@ -314,49 +641,107 @@ func (e *Experience) OnCollect(collector ItemCollector) {
 	}
 }
 
+// Party groups player IDs together for shared XP and friendly-fire
+// protection. Members lists player IDs in join order, starting with the
+// player who created it for as long as that player stays in it.
+type Party struct {
+	ID      string
+	Members []int
+}
+
+// RecordedCommand is one inbound client message, tagged with the tick
+// it arrived on and the connection it came from, so a match can be
+// dumped and later re-applied by Replay.
+type RecordedCommand struct {
+	Tick   int
+	ConnID int
+	Type   string
+	Data   map[string]any
+}
+
 // Game represents the game state and systems
 type Game struct {
 	Players        []*Player
 	Monsters       []*Monster
 	HealingPotions []*HealingPotion
 	Experiences    []*Experience
+	Projectiles    []*Projectile
 	mu             sync.Mutex
-	usedIDs        map[string]map[int]bool // Tracks used IDs by type (player, monster, potion)
+	nextID         map[string]int // Monotonically increasing ID counter per entity type
+
+	// Parties indexes every party by ID, and playerParty lets us find
+	// a player's party (if any) in O(1) from checkWeaponCollisions and
+	// the death-XP split.
+	Parties     map[string]*Party
+	playerParty map[int]string
+
+	// rng is the single source of randomness for the whole match. It's
+	// seeded once at match start so, together with the monotonic IDs
+	// above and TickNumber below, a recorded Commands log can be
+	// replayed to reproduce the exact same match deterministically.
+	rng *rand.Rand
+
+	// TickNumber counts how many simulation ticks have run, advanced
+	// once per iteration of Game.run. Recorded commands are tagged
+	// with the tick they arrived on so Replay can re-apply them at
+	// the right moment.
+	TickNumber int
+
+	// Commands is every inbound client command this match has seen,
+	// in arrival order, for dumping and replaying bug reports.
+	Commands []RecordedCommand
+
+	// Seed is the value this match's rng was constructed with. It's
+	// kept alongside Commands so a live room's match can be dumped
+	// (see DumpReplay) and handed to Replay to reproduce it, not just
+	// a match whose seed the caller already chose ahead of time.
+	Seed int64
 }
 
-// newGame creates a new game instance
+// newGame creates a new game instance seeded from the current time.
 func newGame() *Game {
-	g := &Game{
+	return newGameWithSeed(time.Now().UnixNano())
+}
+
+// newGameWithSeed creates a new game instance whose RNG is seeded
+// deterministically, so the same seed plus the same recorded commands
+// always produces the same match (see Replay).
+func newGameWithSeed(seed int64) *Game {
+	return &Game{
 		Players:        []*Player{},
 		Monsters:       []*Monster{},
 		HealingPotions: []*HealingPotion{},
 		Experiences:    []*Experience{},
-		usedIDs:        make(map[string]map[int]bool),
+		Projectiles:    []*Projectile{},
+		nextID:         make(map[string]int),
+		Parties:        make(map[string]*Party),
+		playerParty:    make(map[int]string),
+		rng:            rand.New(rand.NewSource(seed)),
+		Seed:           seed,
 	}
-	g.usedIDs["player"] = make(map[int]bool)
-	g.usedIDs["monster"] = make(map[int]bool)
-	g.usedIDs["potion"] = make(map[int]bool)
-	g.usedIDs["weapon"] = make(map[int]bool)
-	g.usedIDs["experience"] = make(map[int]bool)
-	return g
 }
 
-// generateID generates a unique ID for a given entity type
-func (g *Game) generateID(entityType string) int {
-	var id int
-	for {
-		id = int(time.Now().UnixNano() % 1000000000)
-		if !g.usedIDs[entityType][id] {
-			g.usedIDs[entityType][id] = true
-			return id
-		}
-		time.Sleep(time.Nanosecond)
-	}
+// DumpReplay returns this match's seed and a snapshot of every command
+// it has recorded so far, suitable for Replay(seed, commands) to
+// reproduce the match through the tick of its last recorded command
+// (Replay does not step past that tick) — e.g. for saving a bug report
+// off a still-running room instead of only a match whose seed the
+// caller chose ahead of time.
+func (g *Game) DumpReplay() (int64, []RecordedCommand) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	commands := make([]RecordedCommand, len(g.Commands))
+	copy(commands, g.Commands)
+	return g.Seed, commands
 }
 
-// releaseID releases an ID when an entity is removed
-func (g *Game) releaseID(entityType string, id int) {
-	delete(g.usedIDs[entityType], id)
+// generateID returns the next monotonically increasing ID for the
+// given entity type. IDs are never reused, so callers no longer need
+// to release them once an entity is removed.
+func (g *Game) generateID(entityType string) int {
+	g.nextID[entityType]++
+	return g.nextID[entityType]
 }
 
 // addNewPlayer creates and adds a new player to the game
@@ -377,7 +762,7 @@ func (g *Game) addNewPlayer(client *Client) *Player {
 		HealthComponent: HealthComponent{
 			Health:      100,
 			MaxHealth:   100,
-			lastHitById: make(map[int]time.Time),
+			lastHitById: make(map[int]int),
 		},
 		ExperienceComponent: ExperienceComponent{
 			Experience: 0,
@@ -394,12 +779,12 @@ func (g *Game) addNewPlayer(client *Client) *Player {
 		WeaponRotationSpeed: 1,
 		Client:              client,
 		Weapons:             []*Weapon{},
+		Effects:             NewEffectManager(),
 	}
 
-	// Generate two weapons
-	for i := 0; i < 2; i++ {
-		player.Weapons = append(player.Weapons, g.newWeapon(player))
-	}
+	// Every player starts with their orbiting melee weapon plus one
+	// ranged weapon they can aim with UseWeapon.
+	player.Weapons = append(player.Weapons, g.newWeapon(player), g.newRangedWeapon(player))
 
 	g.Players = append(g.Players, player)
 	log.Printf("New player %d added to game", id)
@@ -419,9 +804,128 @@ func (g *Game) newWeapon(owner *Player) *Weapon {
 			Height: 20,
 		},
 		OwnerID: owner.ID,
+		Kind:    WeaponKindMelee,
 	}
 }
 
+// newRangedWeapon creates a player's starting Projectile-kind weapon,
+// triggered by a "useWeapon" message aimed at a target point instead of
+// orbiting the player like newWeapon's melee weapon.
+func (g *Game) newRangedWeapon(owner *Player) *Weapon {
+	weaponID := g.generateID("weapon")
+
+	return &Weapon{
+		Entity: Entity{
+			ID:     weaponID,
+			X:      owner.X,
+			Y:      owner.Y,
+			Width:  10,
+			Height: 20,
+		},
+		OwnerID:        owner.ID,
+		Kind:           WeaponKindProjectile,
+		Range:          250,
+		InflictsDamage: InflictsDamage{Damage: owner.Damage},
+	}
+}
+
+// spawnProjectile creates and adds a fired projectile to the game,
+// launched from (x, y) toward (targetX, targetY) at the given speed.
+func (g *Game) spawnProjectile(ownerID int, x, y, targetX, targetY, speed float64, ttl float64, damage int, skipLock bool) *Projectile {
+	if !skipLock {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+
+	id := g.generateID("projectile")
+
+	angle := math.Atan2(targetY-y, targetX-x)
+	projectile := &Projectile{
+		Entity: Entity{
+			ID:     id,
+			X:      x,
+			Y:      y,
+			Width:  6,
+			Height: 6,
+		},
+		OwnerID:        ownerID,
+		VelocityX:      math.Cos(angle) * speed,
+		VelocityY:      math.Sin(angle) * speed,
+		TTL:            ttl,
+		hitTargets:     make(map[int]bool),
+		InflictsDamage: InflictsDamage{Damage: damage},
+	}
+	g.Projectiles = append(g.Projectiles, projectile)
+	return projectile
+}
+
+// UseWeapon triggers a targeted Projectile or AoE weapon at
+// (targetX, targetY). Melee weapons ignore targeting since they orbit
+// the player automatically, so calling this on one is a no-op.
+func (g *Game) UseWeapon(player *Player, weaponIdx int, targetX, targetY float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if weaponIdx < 0 || weaponIdx >= len(player.Weapons) {
+		return fmt.Errorf("invalid weapon index %d", weaponIdx)
+	}
+	weapon := player.Weapons[weaponIdx]
+
+	switch weapon.Kind {
+	case WeaponKindProjectile:
+		const projectileSpeed = 300.0
+		const projectileTTL = 2.0
+		g.spawnProjectile(player.ID, player.X, player.Y, targetX, targetY, projectileSpeed, projectileTTL, weapon.Damage, true)
+
+	case WeaponKindAoE:
+		g.applyAoEDamage(player.ID, targetX, targetY, weapon.Radius, weapon.Damage)
+	}
+
+	return nil
+}
+
+// applyAoEDamage damages every Hittable within radius of (x, y), other
+// than the owner and the owner's party members, exactly once per call. It builds its own short-lived
+// spatial grids rather than scanning every monster/player directly,
+// since it's called straight from UseWeapon and can't reuse the grids
+// CollisionSystem.Update rebuilds once per tick.
+func (g *Game) applyAoEDamage(ownerID int, x, y, radius float64, damage int) {
+	center := &Entity{X: x, Y: y, Width: radius * 2, Height: radius * 2}
+
+	monsterGrid := newSpatialGrid(spatialCellSize)
+	for _, m := range g.Monsters {
+		monsterGrid.Insert(m)
+	}
+	for _, candidate := range monsterGrid.Query(center) {
+		m := candidate.(*Monster)
+		if m.IsDead() || !withinRadius(center, m.GetEntity(), radius) {
+			continue
+		}
+		m.TakeDamage(damage)
+		m.LastHitByPlayerID = ownerID
+	}
+
+	playerGrid := newSpatialGrid(spatialCellSize)
+	for _, p := range g.Players {
+		playerGrid.Insert(p)
+	}
+	for _, candidate := range playerGrid.Query(center) {
+		p := candidate.(*Player)
+		if p.ID == ownerID || g.sameParty(ownerID, p.ID) || !withinRadius(center, p.GetEntity(), radius) {
+			continue
+		}
+		p.TakeDamage(damage)
+	}
+}
+
+// withinRadius reports whether other's center lies within radius of
+// center's center.
+func withinRadius(center, other *Entity, radius float64) bool {
+	dx := center.X - other.X
+	dy := center.Y - other.Y
+	return dx*dx+dy*dy <= radius*radius
+}
+
 // spawnMonster creates and adds a new monster to the game
 func (g *Game) spawnMonster(skipLock bool) *Monster {
 	if !skipLock {
@@ -430,27 +934,31 @@ func (g *Game) spawnMonster(skipLock bool) *Monster {
 	}
 
 	id := g.generateID("monster")
+	monsterType := g.pickMonsterType()
 	monster := &Monster{
 		Entity: Entity{
 			ID:     id,
-			X:      gameMinX + 50 + rand.Float64()*(gameMaxX-gameMinX-100),
-			Y:      gameMinY + 50 + rand.Float64()*(gameMaxY-gameMinY-100),
+			X:      gameMinX + 50 + g.rng.Float64()*(gameMaxX-gameMinX-100),
+			Y:      gameMinY + 50 + g.rng.Float64()*(gameMaxY-gameMinY-100),
 			Width:  20,
 			Height: 20,
 		},
 		HealthComponent: HealthComponent{
 			Health:      60,
 			MaxHealth:   60,
-			lastHitById: make(map[int]time.Time),
+			lastHitById: make(map[int]int),
 		},
 		MovementComponent: MovementComponent{
 			Speed:     30,
-			Direction: rand.Float64() * 2 * math.Pi,
+			Direction: g.rng.Float64() * 2 * math.Pi,
 		},
 		AttackComponent: AttackComponent{
 			Damage: 20,
 		},
 		DropRate: 0.75,
+		Type:     monsterType,
+		Behavior: newBehaviorForType(monsterType),
+		Effects:  NewEffectManager(),
 	}
 	g.Monsters = append(g.Monsters, monster)
 	return monster
@@ -488,8 +996,8 @@ func (g *Game) spawnExperience(x, y float64, amount int, skipLock bool) *Experie
 	id := g.generateID("experience")
 
 	// Random offset from the origin point (where the entity died)
-	offsetX := (rand.Float64() - 0.5) * 30
-	offsetY := (rand.Float64() - 0.5) * 30
+	offsetX := (g.rng.Float64() - 0.5) * 30
+	offsetY := (g.rng.Float64() - 0.5) * 30
 
 	exp := &Experience{
 		Entity: Entity{
@@ -512,8 +1020,6 @@ func (g *Game) removePlayer(playerID int, skipLock bool) {
 		defer g.mu.Unlock()
 	}
 
-	g.releaseID("player", playerID)
-
 	for i, player := range g.Players {
 		if player.ID == playerID {
 			// Remove player by swapping with the last element and truncating
@@ -529,20 +1035,238 @@ func (g *Game) removePlayer(playerID int, skipLock bool) {
 	}
 }
 
+// markPlayerDisconnected detaches a player's Client without removing
+// the Player itself, starting its grace period for a session resume.
+func (g *Game) markPlayerDisconnected(playerID int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, player := range g.Players {
+		if player.ID == playerID {
+			player.Client = nil
+			player.DisconnectedAt = time.Now()
+			return
+		}
+	}
+}
+
+// resumePlayer re-binds a still-live, disconnected Player to a new
+// Client. It returns false if no such player exists (already reaped,
+// wrong ID, or never disconnected).
+func (g *Game) resumePlayer(playerID int, client *Client) (*Player, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, player := range g.Players {
+		if player.ID == playerID && !player.DisconnectedAt.IsZero() {
+			player.Client = client
+			player.DisconnectedAt = time.Time{}
+			return player, true
+		}
+	}
+	return nil, false
+}
+
+// reapDisconnectedPlayers permanently removes players whose grace
+// period has elapsed without a resume.
+func (g *Game) reapDisconnectedPlayers() {
+	remaining := []*Player{}
+	for _, player := range g.Players {
+		if !player.DisconnectedAt.IsZero() && time.Since(player.DisconnectedAt) > playerGracePeriod {
+			log.Printf("Player %d's session expired, removed from game", player.ID)
+			continue
+		}
+		remaining = append(remaining, player)
+	}
+	g.Players = remaining
+}
+
+// RecordCommand appends an inbound client message to the match's
+// command log, tagged with the current tick, so the match can later be
+// replayed with Replay.
+func (g *Game) RecordCommand(connID int, msgType string, data map[string]any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Commands = append(g.Commands, RecordedCommand{Tick: g.TickNumber, ConnID: connID, Type: msgType, Data: data})
+}
+
+// findPlayer returns the player with the given ID, or nil if it isn't
+// in the game (e.g. already disconnected past its grace period).
+func (g *Game) findPlayer(playerID int) *Player {
+	for _, p := range g.Players {
+		if p.ID == playerID {
+			return p
+		}
+	}
+	return nil
+}
+
+// CreateParty creates a new party led by leaderID.
+func (g *Game) CreateParty(id string, leaderID int) (*Party, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.Parties[id]; ok {
+		return nil, fmt.Errorf("party %q already exists", id)
+	}
+	if _, ok := g.playerParty[leaderID]; ok {
+		return nil, fmt.Errorf("player %d is already in a party", leaderID)
+	}
+
+	party := &Party{ID: id, Members: []int{leaderID}}
+	g.Parties[id] = party
+	g.playerParty[leaderID] = id
+	return party, nil
+}
+
+// JoinParty adds playerID to the party identified by id.
+func (g *Game) JoinParty(id string, playerID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	party, ok := g.Parties[id]
+	if !ok {
+		return fmt.Errorf("party %q does not exist", id)
+	}
+	if _, ok := g.playerParty[playerID]; ok {
+		return fmt.Errorf("player %d is already in a party", playerID)
+	}
+
+	party.Members = append(party.Members, playerID)
+	g.playerParty[playerID] = id
+	return nil
+}
+
+// LeaveParty removes playerID from its current party, if any,
+// disbanding the party once its last member leaves.
+func (g *Game) LeaveParty(playerID int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id, ok := g.playerParty[playerID]
+	if !ok {
+		return
+	}
+	delete(g.playerParty, playerID)
+
+	party, ok := g.Parties[id]
+	if !ok {
+		return
+	}
+	for i, memberID := range party.Members {
+		if memberID == playerID {
+			party.Members = append(party.Members[:i], party.Members[i+1:]...)
+			break
+		}
+	}
+	if len(party.Members) == 0 {
+		delete(g.Parties, id)
+	}
+}
+
+// partyOf returns the party playerID belongs to, if any. Callers must
+// already hold g.mu.
+func (g *Game) partyOf(playerID int) (*Party, bool) {
+	id, ok := g.playerParty[playerID]
+	if !ok {
+		return nil, false
+	}
+	party, ok := g.Parties[id]
+	return party, ok
+}
+
+// sameParty reports whether two players are in the same party.
+// Callers must already hold g.mu.
+func (g *Game) sameParty(playerAID, playerBID int) bool {
+	idA, okA := g.playerParty[playerAID]
+	idB, okB := g.playerParty[playerBID]
+	return okA && okB && idA == idB
+}
+
+// distributePartyExp splits expAmount from a kill at (x, y) among
+// killerID's party members within partyExpShareRadius of the kill
+// site, giving the killer itself a bonus on top of its even share. It
+// reports false without awarding anything if no member was in range,
+// so the caller can fall back to dropping free-for-all pickup orbs
+// instead of letting the experience vanish. Callers must already hold
+// g.mu.
+func (g *Game) distributePartyExp(party *Party, killerID int, x, y float64, expAmount int) bool {
+	var nearby []*Player
+	for _, memberID := range party.Members {
+		p := g.findPlayer(memberID)
+		if p == nil {
+			continue
+		}
+		dx := p.X - x
+		dy := p.Y - y
+		if dx*dx+dy*dy <= partyExpShareRadius*partyExpShareRadius {
+			nearby = append(nearby, p)
+		}
+	}
+	if len(nearby) == 0 {
+		return false
+	}
+
+	share := expAmount / len(nearby)
+	if share < 1 {
+		share = 1
+	}
+
+	for _, p := range nearby {
+		amount := share
+		if p.ID == killerID {
+			amount += share * partySoloBonusPercent / 100
+		}
+		leveledUp := p.GainExperience(amount)
+
+		if p.Client == nil {
+			continue
+		}
+		msg, err := json.Marshal(map[string]interface{}{
+			"type":              "partyExpShare",
+			"partyID":           party.ID,
+			"playerID":          p.ID,
+			"experience_gained": amount,
+			"leveled_up":        leveledUp,
+		})
+		if err == nil {
+			p.Client.send <- msg
+		}
+	}
+
+	return true
+}
+
 // CollisionSystem handles collisions between different game entities
 type CollisionSystem struct {
 	game *Game
-	hub  *Hub
+
+	// playerGrid/monsterGrid are uniform grid spatial partitions
+	// rebuilt every Update, so each check below only has to look at
+	// entities near its query area instead of scanning every player
+	// or monster in the game.
+	playerGrid  *spatialGrid
+	monsterGrid *spatialGrid
 }
 
 // NewCollisionSystem creates a new collision system
-func NewCollisionSystem(game *Game, hub *Hub) *CollisionSystem {
-	return &CollisionSystem{game: game, hub: hub}
+func NewCollisionSystem(game *Game) *CollisionSystem {
+	return &CollisionSystem{game: game}
 }
 
 // Update checks and handles all game collisions
 func (cs *CollisionSystem) Update() {
+	cs.playerGrid = newSpatialGrid(spatialCellSize)
+	for _, p := range cs.game.Players {
+		cs.playerGrid.Insert(p)
+	}
+	cs.monsterGrid = newSpatialGrid(spatialCellSize)
+	for _, m := range cs.game.Monsters {
+		cs.monsterGrid.Insert(m)
+	}
+
 	cs.checkWeaponCollisions()
+	cs.checkProjectileCollisions()
 	cs.checkMonsterPlayerCollisions()
 	cs.checkPlayerPotionCollisions()
 	cs.checkPlayerExperienceCollisions()
@@ -553,15 +1277,21 @@ func (cs *CollisionSystem) checkWeaponCollisions() {
 
 	for _, p := range cs.game.Players {
 		for _, w := range p.Weapons {
-			for _, other := range cs.game.Players {
-				if other.ID == w.OwnerID {
-					continue // Skip owner
+			// Only melee weapons deal orbit contact damage; Projectile and
+			// AoE weapons act through UseWeapon.
+			if w.Kind != WeaponKindMelee {
+				continue
+			}
+			for _, candidate := range cs.playerGrid.Query(w.GetEntity()) {
+				other := candidate.(*Player)
+				if other.ID == w.OwnerID || cs.game.sameParty(w.OwnerID, other.ID) {
+					continue // Skip owner and party members (no friendly fire)
 				}
 
 				if w.CheckCollision(other.GetEntity()) {
 					// Check cooldown
 					weaponID := w.ID
-					if other.CheckHitCooldown(weaponID) {
+					if other.CheckHitCooldown(weaponID, cs.game.TickNumber) {
 						other.TakeDamage(p.Damage)
 
 						// Create hit notification
@@ -583,13 +1313,15 @@ func (cs *CollisionSystem) checkWeaponCollisions() {
 					}
 				}
 			}
-			for _, m := range cs.game.Monsters {
+			for _, candidate := range cs.monsterGrid.Query(w.GetEntity()) {
+				m := candidate.(*Monster)
 				if m.Health > 0 && w.CheckCollision(m.GetEntity()) {
-					if !m.CheckHitCooldown(w.ID) {
+					if !m.CheckHitCooldown(w.ID, cs.game.TickNumber) {
 						continue
 					}
 					// Apply damage
 					m.TakeDamage(p.Damage)
+					m.LastHitByPlayerID = p.ID
 
 					// Only notify client if it exists
 					if p.Client != nil {
@@ -618,17 +1350,65 @@ func (cs *CollisionSystem) checkWeaponCollisions() {
 	}
 }
 
+// checkProjectileCollisions handles projectile-to-monster and
+// projectile-to-player collisions, removing projectiles that hit
+// something or ran out of TTL.
+func (cs *CollisionSystem) checkProjectileCollisions() {
+	remaining := []*Projectile{}
+	for _, proj := range cs.game.Projectiles {
+		hit := false
+
+		for _, candidate := range cs.monsterGrid.Query(proj.GetEntity()) {
+			m := candidate.(*Monster)
+			if m.IsDead() || proj.hitTargets[m.ID] {
+				continue
+			}
+			if proj.CheckCollision(m.GetEntity()) {
+				m.TakeDamage(proj.Damage)
+				m.LastHitByPlayerID = proj.OwnerID
+				proj.hitTargets[m.ID] = true
+				hit = true
+			}
+		}
+
+		for _, candidate := range cs.playerGrid.Query(proj.GetEntity()) {
+			p := candidate.(*Player)
+			if p.ID == proj.OwnerID || proj.hitTargets[p.ID] || cs.game.sameParty(proj.OwnerID, p.ID) {
+				continue
+			}
+			if proj.CheckCollision(p.GetEntity()) {
+				p.TakeDamage(proj.Damage)
+				proj.hitTargets[p.ID] = true
+				hit = true
+			}
+		}
+
+		if hit || proj.Expired() {
+			continue
+		}
+		remaining = append(remaining, proj)
+	}
+	cs.game.Projectiles = remaining
+}
+
 // checkMonsterPlayerCollisions handles monster-to-player collisions
 func (cs *CollisionSystem) checkMonsterPlayerCollisions() {
 	for _, m := range cs.game.Monsters {
-		for _, p := range cs.game.Players {
+		for _, candidate := range cs.playerGrid.Query(m.GetEntity()) {
+			p := candidate.(*Player)
 			if m.Health > 0 && m.CheckCollision(p.GetEntity()) {
-				if !p.CheckHitCooldown(m.ID) {
+				if !p.CheckHitCooldown(m.ID, cs.game.TickNumber) {
 					continue
 				}
 				// Apply damage
 				p.TakeDamage(m.Damage)
 
+				// Monster bites have a chance to inflict a lingering
+				// poison DoT on top of the instant hit.
+				if cs.game.rng.Float64() < 0.3 {
+					p.Effects.Add(NewPoisonEffect(m.Damage/4+1, cs.game.TickNumber), p)
+				}
+
 				// Only notify client if it exists
 				if p.Client != nil {
 					hitNotification, err := json.Marshal(map[string]interface{}{
@@ -652,11 +1432,11 @@ func (cs *CollisionSystem) checkPlayerPotionCollisions() {
 	remainingPotions := []*HealingPotion{}
 	for _, potion := range cs.game.HealingPotions {
 		collected := false
-		for _, p := range cs.game.Players {
+		for _, candidate := range cs.playerGrid.Query(potion.GetEntity()) {
+			p := candidate.(*Player)
 			if potion.CheckCollision(p.GetEntity()) {
-				potion.OnCollect(p)
+				potion.OnCollect(p, cs.game.TickNumber)
 
-				cs.game.releaseID("potion", potion.ID)
 				collected = true
 				break
 			}
@@ -673,12 +1453,12 @@ func (cs *CollisionSystem) checkPlayerExperienceCollisions() {
 	remainingExperiences := []*Experience{}
 	for _, exp := range cs.game.Experiences {
 		collected := false
-		for _, p := range cs.game.Players {
+		for _, candidate := range cs.playerGrid.Query(exp.GetEntity()) {
+			p := candidate.(*Player)
 			if exp.CheckCollision(p.GetEntity()) {
 				// Add experience to player
-				exp.OnCollect(p)
+				exp.OnCollect(p, cs.game.TickNumber)
 
-				cs.game.releaseID("experience", exp.ID)
 				collected = true
 				break
 			}
@@ -707,9 +1487,9 @@ func (ms *MonsterSystem) Update(deltaTime float64) {
 		ms.game.spawnMonster(true)
 	}
 
-	// Update monster positions
+	// Update monster positions and AI
 	for _, m := range ms.game.Monsters {
-		m.Move(deltaTime)
+		m.Behavior.Update(m, ms.game, deltaTime)
 	}
 
 	// Remove dead monsters and drop potions
@@ -722,19 +1502,27 @@ func (ms *MonsterSystem) removeDeadMonsters() {
 	for _, m := range ms.game.Monsters {
 		if m.IsDead() {
 			// Drop a healing potion at monster's position
-			if rand.Float64() < m.DropRate {
+			if ms.game.rng.Float64() < m.DropRate {
 				ms.game.spawnHealingPotion(m.X, m.Y, true)
 			}
 
-			// Spawn experience points
-			expAmount := 10 + rand.Intn(10) // 10-19 experience points
-			numExpOrbs := 3 + rand.Intn(3)  // 3-5 experience orbs
+			// Spawn experience points. If the killer is in a party and
+			// a member is close enough to share it with, split the
+			// kill's experience directly among them; otherwise (no
+			// party, or every member too far away) drop free-for-all
+			// pickup orbs so the experience isn't simply lost.
+			expAmount := 10 + ms.game.rng.Intn(10) // 10-19 experience points
 
-			for i := 0; i < numExpOrbs; i++ {
-				ms.game.spawnExperience(m.X, m.Y, expAmount/numExpOrbs, true)
+			shared := false
+			if party, ok := ms.game.partyOf(m.LastHitByPlayerID); ok {
+				shared = ms.game.distributePartyExp(party, m.LastHitByPlayerID, m.X, m.Y, expAmount)
+			}
+			if !shared {
+				numExpOrbs := 3 + ms.game.rng.Intn(3) // 3-5 experience orbs
+				for i := 0; i < numExpOrbs; i++ {
+					ms.game.spawnExperience(m.X, m.Y, expAmount/numExpOrbs, true)
+				}
 			}
-
-			ms.game.releaseID("monster", m.ID)
 			continue
 		}
 		remaining = append(remaining, m)
@@ -745,12 +1533,11 @@ func (ms *MonsterSystem) removeDeadMonsters() {
 // PlayerSystem handles player updates and cleanup
 type PlayerSystem struct {
 	game *Game
-	hub  *Hub
 }
 
 // NewPlayerSystem creates a new player system
-func NewPlayerSystem(game *Game, hub *Hub) *PlayerSystem {
-	return &PlayerSystem{game: game, hub: hub}
+func NewPlayerSystem(game *Game) *PlayerSystem {
+	return &PlayerSystem{game: game}
 }
 
 // Update updates all players and handles removal of dead players
@@ -810,7 +1597,7 @@ func (ps *PlayerSystem) removeDeadPlayers() {
 			// Spawn experience when player dies (half of their current experience)
 			if p.Experience > 0 {
 				expAmount := p.Experience / 2
-				numExpOrbs := 4 + rand.Intn(4) // 4-7 experience orbs
+				numExpOrbs := 4 + ps.game.rng.Intn(4) // 4-7 experience orbs
 
 				for i := 0; i < numExpOrbs; i++ {
 					ps.game.spawnExperience(p.X, p.Y, expAmount/numExpOrbs, true)
@@ -823,24 +1610,54 @@ func (ps *PlayerSystem) removeDeadPlayers() {
 	}
 }
 
+// broadcaster is anything that can fan a message out to the clients
+// watching a game. Both Hub (single shared game) and Room (one game per
+// room) implement it.
+type broadcaster interface {
+	Broadcast(msg []byte)
+}
+
+// Step advances the simulation by one tick: it updates every system,
+// ticks active effects, reaps expired sessions, and advances
+// TickNumber. Callers must already hold g.mu. Both the live game loop
+// (run) and headless Replay share this so a recorded match replays
+// identically to how it was first simulated.
+func (g *Game) Step(deltaTime float64, playerSystem *PlayerSystem, monsterSystem *MonsterSystem, collisionSystem *CollisionSystem) {
+	playerSystem.Update(deltaTime)
+	monsterSystem.Update(deltaTime)
+	for _, proj := range g.Projectiles {
+		proj.Move(deltaTime)
+	}
+	collisionSystem.Update()
+
+	// Tick every active buff/DoT/HoT, removing any past their deadline.
+	// Driven off TickNumber rather than wall-clock time so Replay
+	// reproduces identical effect timing.
+	for _, p := range g.Players {
+		p.Effects.Update(p, g.TickNumber)
+	}
+	for _, m := range g.Monsters {
+		m.Effects.Update(m, g.TickNumber)
+	}
+	g.reapDisconnectedPlayers()
+	g.TickNumber++
+}
+
 // run starts the game loop
-func (g *Game) run(fps int, hub *Hub) {
+func (g *Game) run(fps int, b broadcaster) {
 	deltaTime := 1.0 / float64(fps)
 	ticker := time.NewTicker(time.Second / time.Duration(fps))
 	defer ticker.Stop()
 
 	// Initialize systems
-	playerSystem := NewPlayerSystem(g, hub)
+	playerSystem := NewPlayerSystem(g)
 	monsterSystem := NewMonsterSystem(g)
-	collisionSystem := NewCollisionSystem(g, hub)
+	collisionSystem := NewCollisionSystem(g)
 
 	for range ticker.C {
 		g.mu.Lock()
 
-		// Update all systems
-		playerSystem.Update(deltaTime)
-		monsterSystem.Update(deltaTime)
-		collisionSystem.Update()
+		g.Step(deltaTime, playerSystem, monsterSystem, collisionSystem)
 
 		// Create copies of the game state to send to clients
 		playersCopy := make([]*Player, len(g.Players))
@@ -851,6 +1668,8 @@ func (g *Game) run(fps int, hub *Hub) {
 		copy(potionsCopy, g.HealingPotions)
 		experiencesCopy := make([]*Experience, len(g.Experiences))
 		copy(experiencesCopy, g.Experiences)
+		projectilesCopy := make([]*Projectile, len(g.Projectiles))
+		copy(projectilesCopy, g.Projectiles)
 
 		g.mu.Unlock()
 
@@ -861,11 +1680,124 @@ func (g *Game) run(fps int, hub *Hub) {
 			"monsters":    monstersCopy,
 			"potions":     potionsCopy,
 			"experiences": experiencesCopy,
+			"projectiles": projectilesCopy,
 		})
 		if err != nil {
 			log.Println("error marshalling game info", err)
 			continue
 		}
-		hub.broadcast <- jsonData
+		b.Broadcast(jsonData)
+	}
+}
+
+// Replay reconstructs a match headlessly from its seed and recorded
+// command log. Because every source of non-determinism in Game (RNG,
+// entity IDs, and the tick count itself) is driven from seed and
+// TickNumber rather than wall-clock state, stepping the same number of
+// ticks and re-applying the same commands at the same ticks reproduces
+// the exact match the seed and commands were recorded from.
+func Replay(seed int64, commands []RecordedCommand) *Game {
+	g := newGameWithSeed(seed)
+
+	playerSystem := NewPlayerSystem(g)
+	monsterSystem := NewMonsterSystem(g)
+	collisionSystem := NewCollisionSystem(g)
+
+	lastTick := 0
+	for _, cmd := range commands {
+		if cmd.Tick > lastTick {
+			lastTick = cmd.Tick
+		}
+	}
+
+	// connPlayer maps a connection's ConnID to the Player it joined as,
+	// populated as "join" commands are replayed. The command log has no
+	// other stable way to tie later commands back to their Player,
+	// since the Player's game-assigned ID doesn't exist until "join" is
+	// replayed.
+	connPlayer := make(map[int]int)
+	const deltaTime = 1.0 / simulationFPS
+
+	idx := 0
+	for tick := 0; tick <= lastTick; tick++ {
+		for idx < len(commands) && commands[idx].Tick == tick {
+			applyCommand(g, connPlayer, commands[idx])
+			idx++
+		}
+		g.Step(deltaTime, playerSystem, monsterSystem, collisionSystem)
+	}
+
+	return g
+}
+
+// applyCommand re-applies a single recorded client command to a
+// replaying Game. Room/connection-management message types
+// (create_room, join_room, subscribe, resume, etc.) are recorded by
+// RecordCommand like any other but are no-ops here, since they affect
+// Hub/Room plumbing rather than this Game's simulation state.
+func applyCommand(g *Game, connPlayer map[int]int, cmd RecordedCommand) {
+	switch cmd.Type {
+	case "join":
+		// A live "join" is ignored while the connection still has a Player.
+		if id, ok := connPlayer[cmd.ConnID]; ok && g.findPlayer(id) != nil {
+			return
+		}
+		player := g.addNewPlayer(nil)
+		connPlayer[cmd.ConnID] = player.ID
+
+	case "direction":
+		player := g.replayPlayer(connPlayer, cmd.ConnID)
+		if player == nil {
+			return
+		}
+		if direction, ok := cmd.Data["direction"].(float64); ok {
+			player.Direction = direction
+		}
+
+	case "useWeapon":
+		player := g.replayPlayer(connPlayer, cmd.ConnID)
+		if player == nil {
+			return
+		}
+		weaponIdx, _ := cmd.Data["weaponIdx"].(float64)
+		targetX, _ := cmd.Data["targetX"].(float64)
+		targetY, _ := cmd.Data["targetY"].(float64)
+		g.UseWeapon(player, int(weaponIdx), targetX, targetY)
+
+	case "create_party":
+		player := g.replayPlayer(connPlayer, cmd.ConnID)
+		if player == nil {
+			return
+		}
+		if partyID, ok := cmd.Data["partyID"].(string); ok {
+			g.CreateParty(partyID, player.ID)
+		}
+
+	case "join_party":
+		player := g.replayPlayer(connPlayer, cmd.ConnID)
+		if player == nil {
+			return
+		}
+		if partyID, ok := cmd.Data["partyID"].(string); ok {
+			g.JoinParty(partyID, player.ID)
+		}
+
+	case "leave_party":
+		player := g.replayPlayer(connPlayer, cmd.ConnID)
+		if player == nil {
+			return
+		}
+		g.LeaveParty(player.ID)
+	}
+}
+
+// replayPlayer looks up the Player a connection joined as during
+// replay, returning nil if that connection never joined (or has since
+// been reaped).
+func (g *Game) replayPlayer(connPlayer map[int]int, connID int) *Player {
+	playerID, ok := connPlayer[connID]
+	if !ok {
+		return nil
 	}
+	return g.findPlayer(playerID)
 }
@@ -0,0 +1,45 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+// TestUseWeaponProjectile exercises UseWeapon's Projectile path, which
+// is otherwise unreachable dead code: every starting weapon used to be
+// melee, so newRangedWeapon's WeaponKindProjectile weapon is what makes
+// this path (and checkProjectileCollisions) obtainable in a real match.
+func TestUseWeaponProjectile(t *testing.T) {
+	g := newGameWithSeed(1)
+	client := &Client{send: make(chan []byte, 16)}
+	player := g.addNewPlayer(client)
+
+	if len(player.Weapons) != 2 {
+		t.Fatalf("got %d starting weapons, want 2", len(player.Weapons))
+	}
+
+	rangedIdx := -1
+	for i, w := range player.Weapons {
+		if w.Kind == WeaponKindProjectile {
+			rangedIdx = i
+		}
+	}
+	if rangedIdx == -1 {
+		t.Fatal("no starting weapon has WeaponKindProjectile; projectile path is unreachable")
+	}
+	if player.Weapons[rangedIdx].Damage == 0 {
+		t.Fatal("ranged weapon has zero Damage")
+	}
+
+	if err := g.UseWeapon(player, rangedIdx, player.X+100, player.Y); err != nil {
+		t.Fatalf("UseWeapon returned error: %v", err)
+	}
+
+	if len(g.Projectiles) != 1 {
+		t.Fatalf("got %d projectiles after UseWeapon, want 1", len(g.Projectiles))
+	}
+	if g.Projectiles[0].Damage != player.Weapons[rangedIdx].Damage {
+		t.Fatalf("projectile damage = %d, want %d", g.Projectiles[0].Damage, player.Weapons[rangedIdx].Damage)
+	}
+}
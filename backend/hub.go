@@ -4,29 +4,100 @@
 
 package main
 
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// lobbyBroadcastWorkers/lobbyBroadcastQueueSize/lobbyMaxDropStrikes 設定
+// 大廳廣播的 worker pool，對應 Room 自己的 RoomConfig 預設值
+const (
+	lobbyBroadcastWorkers   = 4
+	lobbyBroadcastQueueSize = 64
+	lobbyMaxDropStrikes     = 5
+)
+
 // Hub 維護活動客戶端集合並向客戶端廣播消息
+// 同時持有多個 Room，讓一台伺服器可以同時host多個獨立對局
 type Hub struct {
-	// 已註冊的客戶端
+	// 尚未加入任何 Room 的客戶端（大廳）
 	clients map[*Client]bool
 
-	// 來自客戶端的入站消息
+	// 來自大廳客戶端的入站消息
 	broadcast chan []byte
 
 	// 客戶端的註冊請求
 	register chan *Client
 
-	// 客戶端的註銷請求
+	// 客戶端的註銷請求（徹底斷線，會關閉 send channel）
 	unregister chan *Client
+
+	// leaveLobby 讓客戶端離開大廳但保留其連線（例如正要加入某個
+	// Room），因此不會關閉 send channel
+	leaveLobby chan *Client
+
+	// rooms 依照房間 ID 索引目前存在的所有 Room
+	rooms map[string]*Room
+
+	// topics 依照主題名稱索引訂閱該主題的客戶端，用來實現細粒度的
+	// pub/sub 推播（例如 "player.42.events"、"game.leaderboard"）
+	topics map[string]map[*Client]bool
+
+	// subscribe/unsubscribe 處理客戶端的訂閱與取消訂閱請求
+	subscribe chan subscription
+
+	unsubscribe chan subscription
+
+	// publish 承載要發送給某個主題所有訂閱者的訊息
+	publish chan topicMessage
+
+	// broker, 若有設定，讓這台伺服器的 broadcast/topic 訊息透過訊息匯流排
+	// 與其他伺服器實例共享，實現水平擴展
+	broker Broker
+
+	// instanceID 是這個 Hub 實例的唯一識別碼，由 AttachBroker 記錄；
+	// 標記訊息來源與避免 loopback（收到自己發出去的訊息）由 Broker
+	// 以它自己建構時的 ID 處理，Hub 本身不讀取這個欄位
+	instanceID string
+
+	// pool 是大廳廣播用的 worker pool，與 Room 共用同一份 dispatch/
+	// strike/evict 實作，取代舊版各自維護一份的做法
+	pool *broadcastPool
+
+	mu sync.Mutex
+}
+
+// subscription 代表一個客戶端對某個主題的訂閱請求
+type subscription struct {
+	client *Client
+	topic  string
 }
 
-// newHub 創建一個新的 Hub
+// topicMessage 是要發送給某個主題所有訂閱者的訊息
+type topicMessage struct {
+	topic   string
+	payload []byte
+}
+
+// newHub 創建一個新的 Hub，並啟動大廳廣播用的 worker pool
 func newHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte),      // 創建廣播通道
-		register:   make(chan *Client),     // 創建註冊通道
-		unregister: make(chan *Client),     // 創建註銷通道
-		clients:    make(map[*Client]bool), // 初始化客戶端映射
+	h := &Hub{
+		broadcast:   make(chan []byte),      // 創建廣播通道
+		register:    make(chan *Client),     // 創建註冊通道
+		unregister:  make(chan *Client),     // 創建註銷通道
+		leaveLobby:  make(chan *Client),     // 創建大廳離開通道（不關閉 send）
+		clients:     make(map[*Client]bool), // 初始化客戶端映射
+		rooms:       make(map[string]*Room),
+		topics:      make(map[string]map[*Client]bool),
+		subscribe:   make(chan subscription),
+		unsubscribe: make(chan subscription),
+		publish:     make(chan topicMessage, 256),
+		pool:        newBroadcastPool(lobbyBroadcastWorkers, lobbyBroadcastQueueSize, lobbyMaxDropStrikes, EvictAfterStrikes),
 	}
+
+	return h
 }
 
 // run 運行 Hub，處理客戶端的註冊、註銷和消息廣播
@@ -38,24 +109,249 @@ func (h *Hub) run() {
 			h.clients[client] = true
 
 		case client := <-h.unregister:
-			// 註銷客戶端
+			// 註銷客戶端（徹底斷線）
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
 			}
+			h.pruneFromTopics(client)
 
-		case message := <-h.broadcast:
-			// 向所有客戶端廣播消息
-			for client := range h.clients {
+		case client := <-h.leaveLobby:
+			// 客戶端離開大廳去加入 Room，連線仍然存活，不關閉 send
+			delete(h.clients, client)
+			h.pruneFromTopics(client)
+
+		case sub := <-h.subscribe:
+			subscribers, ok := h.topics[sub.topic]
+			if !ok {
+				subscribers = make(map[*Client]bool)
+				h.topics[sub.topic] = subscribers
+			}
+			subscribers[sub.client] = true
+
+		case sub := <-h.unsubscribe:
+			if subscribers, ok := h.topics[sub.topic]; ok {
+				delete(subscribers, sub.client)
+				if len(subscribers) == 0 {
+					delete(h.topics, sub.topic)
+				}
+			}
+
+		case tm := <-h.publish:
+			for client := range h.topics[tm.topic] {
 				select {
-				case client.send <- message:
+				case client.send <- tm.payload:
 					// 成功發送消息
 				default:
-					// 如果發送失敗，關閉客戶端連接並從 Hub 中移除
-					close(client.send)
-					delete(h.clients, client)
+					// 發送失敗：交給 evictSubscriber 處理，它會判斷這個
+					// 客戶端屬於大廳還是某個 Room 再關閉
+					h.evictSubscriber(client)
+				}
+			}
+
+		case message := <-h.broadcast:
+			// 向所有客戶端廣播消息，交給 worker pool 平行處理
+			h.dispatchBroadcast(message)
+
+		case client := <-h.pool.evict:
+			// worker 放棄送達的客戶端，只有這個 goroutine 能動 clients
+			if _, ok := h.clients[client]; ok {
+				delete(h.clients, client)
+				close(client.send)
+				h.pool.clearStrikes(client)
+				h.pruneFromTopics(client)
+			}
+		}
+	}
+}
+
+// dispatchBroadcast snapshots the lobby's current clients and hands
+// them to the shared broadcastPool, instead of one goroutine walking
+// every client itself.
+func (h *Hub) dispatchBroadcast(data []byte) {
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.pool.dispatch(clients, data)
+}
+
+// CreateRoom 建立一個新的 Room 並加入 Hub.rooms，若 ID 已存在則回傳錯誤
+func (h *Hub) CreateRoom(id string) (*Room, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.rooms[id]; ok {
+		return nil, fmt.Errorf("room %q already exists", id)
+	}
+
+	room := newRoom(id, h)
+	h.rooms[id] = room
+	return room, nil
+}
+
+// GetRoom 依照 ID 取得 Room，若不存在回傳 false
+func (h *Hub) GetRoom(id string) (*Room, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[id]
+	return room, ok
+}
+
+// ListRooms 回傳目前所有房間的 ID
+func (h *Hub) ListRooms() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ids := make([]string, 0, len(h.rooms))
+	for id := range h.rooms {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// LeaveRoom 將客戶端從其所在的 Room 移除
+func (h *Hub) LeaveRoom(client *Client) {
+	if client.room == nil {
+		return
+	}
+	client.room.unregister <- client
+	client.room = nil
+}
+
+// LeaveLobby removes client from the lobby's client set (and its topic
+// subscriptions) without closing its send channel, for a still-live
+// client that is moving into a Room. Use unregister instead for a
+// client that has actually disconnected.
+func (h *Hub) LeaveLobby(client *Client) {
+	h.leaveLobby <- client
+}
+
+// Broadcast implements the broadcaster interface for code paths that
+// still operate on the lobby-wide Hub instead of a specific Room.
+func (h *Hub) Broadcast(msg []byte) {
+	h.broadcast <- msg
+	h.publishToBroker("broadcast", msg)
+}
+
+// AttachBroker wires a Broker into the Hub so broadcast and topic
+// messages are shared with every other instance connected to it, and
+// messages originating elsewhere are re-injected into local delivery.
+// instanceID is only recorded on the Hub; dropping this instance's own
+// messages on the way back in is done by the Broker itself, using the
+// ID it was constructed with, so callers pass that same ID here.
+func (h *Hub) AttachBroker(broker Broker, instanceID string) error {
+	h.broker = broker
+	h.instanceID = instanceID
+
+	messages, err := broker.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range messages {
+			switch {
+			case msg.RoutingKey == "broadcast":
+				h.broadcast <- msg.Payload
+			case strings.HasPrefix(msg.RoutingKey, "topic."):
+				topic := strings.TrimPrefix(msg.RoutingKey, "topic.")
+				h.publish <- topicMessage{topic: topic, payload: msg.Payload}
+			case strings.HasPrefix(msg.RoutingKey, "room."):
+				roomID := strings.TrimPrefix(msg.RoutingKey, "room.")
+				if room, ok := h.GetRoom(roomID); ok {
+					room.broadcastLocal(ChannelPlayer, msg.Payload)
 				}
+			default:
+				log.Println("broker: unknown routing key:", msg.RoutingKey)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// publishRoomMessage forwards a room's game-state broadcast to the
+// broker under that room's own routing key, so another instance hosting
+// a Room with the same ID can stay in sync. A no-op without a broker.
+func (h *Hub) publishRoomMessage(roomID string, msg []byte) {
+	h.publishToBroker("room."+roomID, msg)
+}
+
+// publishToBroker forwards msg to the broker under routingKey if one is
+// attached. It is a no-op otherwise so Hub works standalone.
+func (h *Hub) publishToBroker(routingKey string, msg []byte) {
+	if h.broker == nil {
+		return
+	}
+	if err := h.broker.Publish(routingKey, msg); err != nil {
+		log.Println("broker: publish failed:", err)
+	}
+}
+
+// evictSubscriber removes a topic subscriber whose send buffer was full.
+// A client can stay subscribed to topics after joining a Room, so its
+// send channel may be owned by that Room rather than the lobby; closing
+// it here directly would race (or double-close) against the Room's own
+// eviction path. Route it back through the Room's own pool instead, and
+// only close/delete directly for a client still in the lobby. The send
+// to the Room's pool is non-blocking: this runs on the Hub's goroutine,
+// not the Room's run loop, so blocking here on a momentarily-full evict
+// channel would risk deadlocking the two against each other. A dropped
+// attempt isn't lost — the client will be struck again on its next
+// failed send.
+func (h *Hub) evictSubscriber(client *Client) {
+	h.pruneFromTopics(client)
+	if client.room != nil {
+		select {
+		case client.room.pool.evict <- client:
+		default:
+		}
+		return
+	}
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// pruneFromTopics removes client from every topic it was subscribed to.
+// Called whenever a client disconnects so closed clients don't linger
+// as phantom subscribers.
+func (h *Hub) pruneFromTopics(client *Client) {
+	for topic, subscribers := range h.topics {
+		if _, ok := subscribers[client]; ok {
+			delete(subscribers, client)
+			if len(subscribers) == 0 {
+				delete(h.topics, topic)
 			}
 		}
 	}
 }
+
+// Subscribe adds client as a subscriber of topic.
+func (h *Hub) Subscribe(client *Client, topic string) {
+	h.subscribe <- subscription{client: client, topic: topic}
+}
+
+// Unsubscribe removes client from topic.
+func (h *Hub) Unsubscribe(client *Client, topic string) {
+	h.unsubscribe <- subscription{client: client, topic: topic}
+}
+
+// Publish sends msg to every client currently subscribed to topic.
+func (h *Hub) Publish(topic string, msg []byte) {
+	h.publish <- topicMessage{topic: topic, payload: msg}
+	h.publishToBroker("topic."+topic, msg)
+}
+
+// Roster returns the presence snapshot for roomID's currently connected
+// players, for admin/debug endpoints.
+func (h *Hub) Roster(roomID string) ([]RosterEntry, bool) {
+	room, ok := h.GetRoom(roomID)
+	if !ok {
+		return nil, false
+	}
+	return room.Roster(), true
+}
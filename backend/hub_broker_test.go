@@ -0,0 +1,120 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// twoHubsSharingRoom spins up two Hub instances attached to the same
+// memoryBus, each hosting its own independent Room instance under the
+// same room ID, and joins one client to each — standing in for two
+// server instances behind a load balancer both asked to host "roomID".
+func twoHubsSharingRoom(t *testing.T, roomID string) (room1, room2 *Room, client1, client2 *Client) {
+	t.Helper()
+
+	bus := newMemoryBus()
+
+	h1 := newHub()
+	go h1.run()
+	if err := h1.AttachBroker(&memoryBroker{bus: bus, instanceID: "instance1"}, "instance1"); err != nil {
+		t.Fatalf("h1.AttachBroker: %v", err)
+	}
+	room1, err := h1.CreateRoom(roomID)
+	if err != nil {
+		t.Fatalf("h1.CreateRoom: %v", err)
+	}
+
+	h2 := newHub()
+	go h2.run()
+	if err := h2.AttachBroker(&memoryBroker{bus: bus, instanceID: "instance2"}, "instance2"); err != nil {
+		t.Fatalf("h2.AttachBroker: %v", err)
+	}
+	room2, err2 := h2.CreateRoom(roomID)
+	if err2 != nil {
+		t.Fatalf("h2.CreateRoom: %v", err2)
+	}
+
+	client1 = newTestClient()
+	room1.join(client1, ChannelPlayer)
+
+	client2 = newTestClient()
+	room2.join(client2, ChannelPlayer)
+
+	return room1, room2, client1, client2
+}
+
+// TestTwoHubsSharePresenceAcrossBroker verifies that a presence event
+// from one instance's Room reaches a client connected to another
+// instance's Room for the same room ID, via the shared Broker — the
+// fan-out the review asked be restricted to informational events.
+func TestTwoHubsSharePresenceAcrossBroker(t *testing.T) {
+	room1, _, _, client2 := twoHubsSharingRoom(t, "shared-room")
+
+	room1.broadcastPresence("join", 42)
+
+	// client2 also receives room2's own Game-loop ticks, so look for the
+	// presence event itself rather than taking whatever arrives first.
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case msg := <-client2.send:
+			if bytes.Contains(msg, []byte(`"type":"presence"`)) {
+				return
+			}
+		case <-deadline:
+			t.Fatal("client2 never received room1's presence event")
+		}
+	}
+}
+
+// TestRoomBroadcastStaysLocalAcrossBroker verifies that Room.Broadcast
+// (the per-tick authoritative game-state snapshot) is never published
+// to the broker: since each instance runs its own independent
+// simulation for the same room ID, replaying one instance's raw state
+// into another would have the two overwrite each other rather than
+// validly scaling horizontally. Both rooms also run their own Game
+// loop, which broadcasts its own local ticks to its own clients the
+// whole time this test runs, so this looks for the specific marker
+// room1.Broadcast sent rather than asserting client2 gets no traffic
+// at all.
+func TestRoomBroadcastStaysLocalAcrossBroker(t *testing.T) {
+	room1, _, client1, client2 := twoHubsSharingRoom(t, "shared-room-2")
+
+	marker := []byte(`{"type":"test-marker-state"}`)
+	room1.Broadcast(marker)
+
+	// client1 is in room1, so it should receive the local broadcast
+	// (possibly interleaved with room1's own Game-loop ticks).
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case msg := <-client1.send:
+			if bytes.Equal(msg, marker) {
+				goto sawMarkerOnClient1
+			}
+		case <-deadline:
+			t.Fatal("client1 never received room1's local broadcast")
+		}
+	}
+sawMarkerOnClient1:
+
+	// client2 is in room2, a different instance's simulation of the
+	// same room ID; it must never see room1's marker broadcast, no
+	// matter how much of its own room2 Game-loop traffic it gets.
+	until := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg := <-client2.send:
+			if bytes.Equal(msg, marker) {
+				t.Fatal("client2 received room1's local-only broadcast across the broker")
+			}
+		case <-until:
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Client with a buffered send channel, standing
+// in for a live connection without a real websocket.
+func newTestClient() *Client {
+	return &Client{send: make(chan []byte, 4)}
+}
+
+// recv drains one message from client.send, failing the test if none
+// arrives within a short timeout.
+func recv(t *testing.T, client *Client) []byte {
+	t.Helper()
+	select {
+	case msg := <-client.send:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
+
+// assertNoMessage fails the test if a message arrives on client.send
+// within a short window, i.e. it was not a subscriber of the topic
+// something else just published to.
+func assertNoMessage(t *testing.T, client *Client) {
+	t.Helper()
+	select {
+	case msg := <-client.send:
+		t.Fatalf("received unexpected message %q", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestHubPublishReachesOnlySubscribers verifies that a message
+// published to a topic only reaches clients subscribed to that topic,
+// not clients subscribed to a different one.
+func TestHubPublishReachesOnlySubscribers(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	subA := newTestClient()
+	subB := newTestClient()
+	h.Subscribe(subA, "topic.a")
+	h.Subscribe(subB, "topic.b")
+
+	h.Publish("topic.a", []byte("hello-a"))
+
+	if got := recv(t, subA); string(got) != "hello-a" {
+		t.Fatalf("subA got %q, want %q", got, "hello-a")
+	}
+	assertNoMessage(t, subB)
+}
+
+// TestHubUnregisterPrunesFromEveryTopic verifies that a client that
+// disconnects (via unregister) is removed from every topic it was
+// subscribed to, so it doesn't linger as a phantom subscriber. If it
+// weren't pruned, the Publish calls below would try to send to (or
+// close) the client's already-closed send channel and panic.
+func TestHubUnregisterPrunesFromEveryTopic(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	client := newTestClient()
+	h.register <- client
+	h.Subscribe(client, "topic.a")
+	h.Subscribe(client, "topic.b")
+
+	h.unregister <- client
+
+	h.Publish("topic.a", []byte("after-unregister"))
+	h.Publish("topic.b", []byte("after-unregister"))
+
+	// Give run's select loop time to process both publishes before the
+	// test (and its goroutines) exit.
+	time.Sleep(50 * time.Millisecond)
+}
@@ -9,11 +9,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
 // 設定服務器監聽的地址，預設為 ":30604"
 var addr = flag.String("addr", ":30604", "http service address")
 
+// amqpURL, 若有設定，讓這個伺服器實例透過 RabbitMQ 與其他實例共享
+// broadcast/topic 訊息，以支援水平擴展；預設為空代表單機模式
+var amqpURL = flag.String("amqp-url", "", "RabbitMQ URL for cross-instance broadcast fan-out (disabled if empty)")
+
 // serveHome 處理首頁請求
 // 如果訪問的不是根路徑 "/"，返回 404 錯誤
 // 如果請求方法不是 GET，返回 405 錯誤
@@ -31,6 +37,16 @@ func serveHome(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "home.html")
 }
 
+// hostname returns the machine's hostname, falling back to "unknown"
+// if it can't be determined, for use in per-instance broker IDs.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
 func main() {
 	// 解析命令行參數
 	flag.Parse()
@@ -40,14 +56,25 @@ func main() {
 	// 在後台運行 Hub
 	go hub.run()
 
-	game := newGame()
-	go game.run(60, hub)
+	// 若有指定 RabbitMQ URL，將這個實例接上訊息匯流排，讓
+	// broadcast/topic 訊息在多個伺服器實例之間共享
+	if *amqpURL != "" {
+		instanceID := fmt.Sprintf("%s-%d", hostname(), time.Now().UnixNano())
+		broker, err := NewRabbitMQBroker(*amqpURL, instanceID, []string{"broadcast", "topic.#", "room.#"})
+		if err != nil {
+			log.Fatal("failed to connect to RabbitMQ: ", err)
+		}
+		if err := hub.AttachBroker(broker, instanceID); err != nil {
+			log.Fatal("failed to attach broker: ", err)
+		}
+		fmt.Println("Connected to RabbitMQ broker as instance", instanceID)
+	}
 
 	// 註冊路由處理函數
 	http.HandleFunc("/", serveHome) // 處理首頁請求
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		// Player will be created when the client sends a join message
-		serveWs(hub, game, w, r)
+		// Clients join/create a room before a Player is created for them
+		serveWs(hub, w, r)
 	})
 
 	fmt.Println("Server is running on port", *addr)
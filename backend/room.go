@@ -0,0 +1,281 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Channel names used to group clients within a Room so gameplay updates
+// and lobby/spectator chat can be fanned out to different subsets
+// without each client needing its own connection.
+const (
+	ChannelPlayer    = "player"
+	ChannelSpectator = "spectator"
+)
+
+// SlowClientPolicy decides what a broadcastPool worker does when a client's
+// send buffer is full.
+type SlowClientPolicy int
+
+const (
+	// EvictAfterStrikes tolerates MaxDropStrikes consecutive full
+	// buffers before dropping the client, so a transient stall doesn't
+	// cost it the connection.
+	EvictAfterStrikes SlowClientPolicy = iota
+
+	// DropOldest discards the client's oldest queued message to make
+	// room for the new one instead of ever evicting it, trading
+	// freshness (the client may miss a tick) for connection stability.
+	DropOldest
+)
+
+// RoomConfig tunes how a Room delivers broadcasts. The zero value is
+// not usable; use defaultRoomConfig unless a room has unusual load.
+type RoomConfig struct {
+	// Workers is how many goroutines fan a broadcast out to clients in
+	// parallel, instead of one goroutine walking every client.
+	Workers int
+
+	// JobQueueSize bounds how many pending broadcast jobs can queue up
+	// for the worker pool before dispatch blocks the Room's run loop.
+	JobQueueSize int
+
+	// MaxDropStrikes is how many consecutive full send buffers a
+	// client can rack up before it is evicted, under EvictAfterStrikes.
+	MaxDropStrikes int
+
+	// SlowClientPolicy decides how a worker handles a client whose send
+	// buffer is full.
+	SlowClientPolicy SlowClientPolicy
+}
+
+// defaultRoomConfig is used by newRoom for every room created through
+// Hub.CreateRoom.
+var defaultRoomConfig = RoomConfig{
+	Workers:          4,
+	JobQueueSize:     64,
+	MaxDropStrikes:   5,
+	SlowClientPolicy: EvictAfterStrikes,
+}
+
+// Room owns an independent game instance along with the set of clients
+// currently connected to it. Each Room runs its own Game loop and
+// broadcast goroutine, so a Hub can host many concurrent games.
+type Room struct {
+	ID string
+
+	hub *Hub
+
+	game *Game
+
+	config RoomConfig
+
+	// clients maps a connected client to the set of channels it belongs
+	// to within this room (e.g. "player" vs "spectator").
+	clients map[*Client]map[string]bool
+
+	// broadcast carries messages destined for a specific channel.
+	broadcast chan roomMessage
+
+	// register/unregister add or remove a client from the room.
+	register chan roomRegistration
+
+	unregister chan *Client
+
+	// pool fans broadcast messages out to this room's clients across a
+	// worker pool instead of one goroutine walking every client.
+	pool *broadcastPool
+}
+
+// roomMessage is a message scoped to one channel within a Room.
+type roomMessage struct {
+	channel string
+	data    []byte
+}
+
+// roomRegistration requests that a client be added to a room on a
+// specific channel (player or spectator).
+type roomRegistration struct {
+	client  *Client
+	channel string
+}
+
+// newRoom creates a Room with its own Game and starts its run loops.
+func newRoom(id string, hub *Hub) *Room {
+	return newRoomWithConfig(id, hub, defaultRoomConfig)
+}
+
+// newRoomWithConfig creates a Room using a custom RoomConfig, useful
+// for rooms with unusual load profiles.
+func newRoomWithConfig(id string, hub *Hub, config RoomConfig) *Room {
+	r := &Room{
+		ID:         id,
+		hub:        hub,
+		game:       newGame(),
+		config:     config,
+		clients:    make(map[*Client]map[string]bool),
+		broadcast:  make(chan roomMessage, 256),
+		register:   make(chan roomRegistration),
+		unregister: make(chan *Client),
+		pool:       newBroadcastPool(config.Workers, config.JobQueueSize, config.MaxDropStrikes, config.SlowClientPolicy),
+	}
+
+	go r.game.run(simulationFPS, r)
+	go r.run()
+
+	return r
+}
+
+// Broadcast implements the broadcaster interface used by Game.run. It
+// fans the game state out to every player in the room. This is LOCAL
+// ONLY, deliberately not published to the broker: each instance runs
+// its own authoritative simulation for a room, so sharing one
+// instance's raw per-tick snapshot with another that is independently
+// simulating the same room would just have the two overwrite each
+// other. broadcastPresence below publishes the informational events
+// that are safe (and useful) to share across instances.
+func (r *Room) Broadcast(msg []byte) {
+	r.broadcastLocal(ChannelPlayer, msg)
+}
+
+// broadcastLocal delivers msg to channel's subscribers in this room
+// only, without publishing to the broker. It is the target both of
+// Broadcast (which is local only as well) and of the Hub's broker
+// dispatch for "room." routing keys, which must not re-publish what it
+// just received.
+func (r *Room) broadcastLocal(channel string, msg []byte) {
+	r.broadcast <- roomMessage{channel: channel, data: msg}
+}
+
+// BroadcastToChannel sends msg to every client subscribed to channel.
+func (r *Room) BroadcastToChannel(channel string, msg []byte) {
+	r.broadcastLocal(channel, msg)
+}
+
+// run processes registration and broadcast events for the room.
+func (r *Room) run() {
+	for {
+		select {
+		case reg := <-r.register:
+			channels, ok := r.clients[reg.client]
+			if !ok {
+				channels = make(map[string]bool)
+				r.clients[reg.client] = channels
+			}
+			channels[reg.channel] = true
+
+		case client := <-r.unregister:
+			if _, ok := r.clients[client]; ok {
+				delete(r.clients, client)
+				if client.player != nil {
+					// Keep the Player around for a grace period instead
+					// of removing it outright, so the client can resume
+					// the same Player with a session token on reconnect.
+					playerID := client.player.ID
+					r.game.markPlayerDisconnected(playerID)
+					client.player = nil
+					r.broadcastPresence("leave", playerID)
+				}
+			}
+
+		case rm := <-r.broadcast:
+			targets := make([]*Client, 0, len(r.clients))
+			for client, channels := range r.clients {
+				if channels[rm.channel] {
+					targets = append(targets, client)
+				}
+			}
+			r.pool.dispatch(targets, rm.data)
+
+		case client := <-r.pool.evict:
+			if _, ok := r.clients[client]; ok {
+				delete(r.clients, client)
+				close(client.send)
+				r.pool.clearStrikes(client)
+				if client.player != nil {
+					playerID := client.player.ID
+					r.game.markPlayerDisconnected(playerID)
+					client.player = nil
+					r.broadcastPresence("leave", playerID)
+				}
+			}
+		}
+	}
+}
+
+// join adds client to the room on the given channel (defaulting to the
+// player channel) and binds the client to the room's game.
+func (r *Room) join(client *Client, channel string) {
+	if channel == "" {
+		channel = ChannelPlayer
+	}
+	client.room = r
+	r.register <- roomRegistration{client: client, channel: channel}
+}
+
+// RosterEntry is a presence snapshot for one connected player, surfaced
+// in "roster" messages and admin/debug endpoints.
+type RosterEntry struct {
+	PlayerID    int       `json:"playerID"`
+	DisplayName string    `json:"displayName"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// Roster returns a snapshot of every currently connected player in the
+// room.
+func (r *Room) Roster() []RosterEntry {
+	r.game.mu.Lock()
+	defer r.game.mu.Unlock()
+
+	roster := make([]RosterEntry, 0, len(r.game.Players))
+	for _, p := range r.game.Players {
+		if p.Client == nil {
+			continue
+		}
+		roster = append(roster, RosterEntry{
+			PlayerID:    p.ID,
+			DisplayName: p.Client.DisplayName,
+			ConnectedAt: p.Client.ConnectedAt,
+		})
+	}
+	return roster
+}
+
+// sendRoster sends a roster snapshot to a single client, e.g. right
+// after it joins or resumes.
+func (r *Room) sendRoster(client *Client) {
+	msg, err := json.Marshal(map[string]any{
+		"type":    "roster",
+		"players": r.Roster(),
+	})
+	if err != nil {
+		return
+	}
+	select {
+	case client.send <- msg:
+	default:
+	}
+}
+
+// broadcastPresence announces a player joining or leaving to every
+// client in the room, and — when the Hub has a Broker attached —
+// publishes it under this room's routing key so another instance
+// hosting the same room ID shows the same roster. Unlike Broadcast,
+// presence events are informational rather than authoritative
+// simulation state, so sharing them across instances is safe.
+func (r *Room) broadcastPresence(event string, playerID int) {
+	msg, err := json.Marshal(map[string]any{
+		"type":     "presence",
+		"event":    event,
+		"playerID": playerID,
+	})
+	if err != nil {
+		return
+	}
+	r.BroadcastToChannel(ChannelPlayer, msg)
+	r.hub.publishRoomMessage(r.ID, msg)
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 The Gorilla WebSocket Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// sessionTokenTTL controls how long a resume token stays valid after
+// being issued.
+const sessionTokenTTL = 5 * time.Minute
+
+// sessionSecret signs session tokens with HMAC so a reconnecting client
+// can prove it owns a given playerID/roomID pair without the server
+// keeping any server-side session store. It is generated once per
+// process, which is enough to make tokens unforgeable for the lifetime
+// of a server instance.
+var sessionSecret = newSessionSecret()
+
+func newSessionSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("session: failed to generate secret: " + err.Error())
+	}
+	return secret
+}
+
+// sessionClaims is the payload carried by a resume token.
+type sessionClaims struct {
+	PlayerID int    `json:"playerID"`
+	RoomID   string `json:"roomID"`
+	Expiry   int64  `json:"expiry"` // unix seconds
+}
+
+// signSessionToken mints a token binding playerID to roomID, valid for
+// sessionTokenTTL from now.
+func signSessionToken(playerID int, roomID string) string {
+	claims := sessionClaims{
+		PlayerID: playerID,
+		RoomID:   roomID,
+		Expiry:   time.Now().Add(sessionTokenTTL).Unix(),
+	}
+
+	payload, _ := json.Marshal(claims)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + signPayload(encodedPayload)
+}
+
+// verifySessionToken checks the token's signature and expiry, returning
+// the claims it carries if valid.
+func verifySessionToken(token string) (sessionClaims, error) {
+	encodedPayload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return sessionClaims{}, errors.New("session: malformed token")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signPayload(encodedPayload))) {
+		return sessionClaims{}, errors.New("session: invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return sessionClaims{}, err
+	}
+
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return sessionClaims{}, err
+	}
+
+	if time.Now().Unix() > claims.Expiry {
+		return sessionClaims{}, errors.New("session: token expired")
+	}
+
+	return claims, nil
+}
+
+// signPayload computes the HMAC-SHA256 signature of an encoded payload.
+func signPayload(encodedPayload string) string {
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(encodedPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
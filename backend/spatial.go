@@ -0,0 +1,55 @@
+package main
+
+import "math"
+
+// spatialCellSize is the width/height of one cell in the uniform grid
+// used to speed up collision queries over the 1200x800 world. It's
+// comfortably larger than any entity so a query never needs to look
+// beyond a 3x3 block of cells around its target area.
+const spatialCellSize = 100.0
+
+// spatialKey identifies one cell in the grid by its integer coordinates.
+type spatialKey struct {
+	cx, cy int
+}
+
+// spatialGrid is a uniform grid spatial partition, rebuilt once per
+// tick, that lets a collision system query only the entities near a
+// given area instead of scanning every entity in the game.
+type spatialGrid struct {
+	cellSize float64
+	cells    map[spatialKey][]Collidable
+}
+
+// newSpatialGrid creates an empty grid with the given cell size.
+func newSpatialGrid(cellSize float64) *spatialGrid {
+	return &spatialGrid{cellSize: cellSize, cells: make(map[spatialKey][]Collidable)}
+}
+
+// keyFor returns the cell an (x, y) point falls into.
+func (g *spatialGrid) keyFor(x, y float64) spatialKey {
+	return spatialKey{cx: int(math.Floor(x / g.cellSize)), cy: int(math.Floor(y / g.cellSize))}
+}
+
+// Insert adds entity to the cell its center falls into.
+func (g *spatialGrid) Insert(entity Collidable) {
+	e := entity.GetEntity()
+	key := g.keyFor(e.X, e.Y)
+	g.cells[key] = append(g.cells[key], entity)
+}
+
+// Query returns every entity inserted into a cell overlapping aabb's
+// bounding box, expanded by one cell size so entities whose center
+// landed in a neighboring cell are still found.
+func (g *spatialGrid) Query(aabb *Entity) []Collidable {
+	minKey := g.keyFor(aabb.X-aabb.Width/2-g.cellSize, aabb.Y-aabb.Height/2-g.cellSize)
+	maxKey := g.keyFor(aabb.X+aabb.Width/2+g.cellSize, aabb.Y+aabb.Height/2+g.cellSize)
+
+	var results []Collidable
+	for cx := minKey.cx; cx <= maxKey.cx; cx++ {
+		for cy := minKey.cy; cy <= maxKey.cy; cy++ {
+			results = append(results, g.cells[spatialKey{cx: cx, cy: cy}]...)
+		}
+	}
+	return results
+}